@@ -0,0 +1,602 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DIVIgor/gator/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+//go:embed api/openapi.json
+var openAPISpec []byte
+
+const defaultAPIPageSize int = 20
+const maxAPIPageSize int = 200
+const apiBodyLimit int64 = 1 << 20
+
+
+// JSON representations of the API resources
+type apiUser struct {
+	Name string `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiFeed struct {
+	ID int32 `json:"id"`
+	Name string `json:"name"`
+	URL string `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	LastFetchError *string `json:"last_fetch_error"`
+}
+
+type apiFollow struct {
+	FeedID int32 `json:"feed_id"`
+	Name string `json:"name"`
+	URL string `json:"url"`
+	Tags []string `json:"tags"`
+}
+
+type apiPost struct {
+	ID int32 `json:"id"`
+	Title string `json:"title"`
+	URL string `json:"url"`
+	Description string `json:"description,omitempty"`
+	FeedID int32 `json:"feed_id"`
+	FeedName string `json:"feed_name"`
+	PublishedAt time.Time `json:"published_at"`
+	FetchedAt time.Time `json:"fetched_at"`
+	ReadAt *time.Time `json:"read_at"`
+}
+
+// A page of posts, NextAfter is passed as after to get the next one
+type apiPostPage struct {
+	Posts []apiPost `json:"posts"`
+	NextAfter *int32 `json:"next_after"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// Handler of a route under a user, the user is already loaded
+type apiUserHandler func(s *state, w http.ResponseWriter, r *http.Request, user database.User)
+
+
+func newAPIFeed(feed database.Feed) apiFeed {
+	out := apiFeed{ID: feed.ID, Name: feed.Name, URL: feed.Url, CreatedAt: feed.CreatedAt}
+	if feed.LastFetchedAt.Valid {
+		out.LastFetchedAt = &feed.LastFetchedAt.Time
+	}
+	if feed.LastFetchError.Valid {
+		out.LastFetchError = &feed.LastFetchError.String
+	}
+	return out
+}
+
+func newAPIPost(post database.GetPostsForUserRow) apiPost {
+	out := apiPost{
+		ID: post.ID,
+		Title: post.Title,
+		URL: post.Url,
+		Description: post.Description.String,
+		FeedID: post.FeedID,
+		FeedName: post.FeedName,
+		PublishedAt: post.PublishedAt,
+		FetchedAt: post.CreatedAt,
+	}
+	if post.ReadAt.Valid {
+		out.ReadAt = &post.ReadAt.Time
+	}
+	return out
+}
+
+func respondJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Printf("Couldn't write response: %v", err)
+	}
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, apiError{Error: message})
+}
+
+// Log unexpected errors and hide them from clients
+func respondInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	respondError(w, http.StatusInternalServerError, "internal error")
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, body any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiBodyLimit))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// Require the bearer token on every API request when one is set
+func apiAuth(token string, next http.Handler) http.Handler {
+	if token == "" {return next}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gator"`)
+			respondError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Load the user named in the path for the handler
+func withAPIUser(s *state, handler apiUserHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.db.GetUser(r.Context(), r.PathValue("user"))
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		if err != nil {
+			respondInternalError(w, r, err)
+			return
+		}
+		handler(s, w, r, user)
+	}
+}
+
+// Routes of the JSON API, versioned under /v1
+func newAPIMux(s *state) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+	mux.HandleFunc("GET /v1/users", func(w http.ResponseWriter, r *http.Request) {
+		handlerAPIUsers(s, w, r)
+	})
+	mux.HandleFunc("POST /v1/users", func(w http.ResponseWriter, r *http.Request) {
+		handlerAPICreateUser(s, w, r)
+	})
+	mux.HandleFunc("GET /v1/feeds", func(w http.ResponseWriter, r *http.Request) {
+		handlerAPIFeeds(s, w, r)
+	})
+	mux.HandleFunc("GET /v1/feeds/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlerAPIFeed(s, w, r)
+	})
+	mux.HandleFunc("GET /v1/users/{user}/follows", withAPIUser(s, handlerAPIFollows))
+	mux.HandleFunc("POST /v1/users/{user}/follows", withAPIUser(s, handlerAPICreateFollow))
+	mux.HandleFunc("DELETE /v1/users/{user}/follows/{feed}", withAPIUser(s, handlerAPIDeleteFollow))
+	mux.HandleFunc("GET /v1/users/{user}/posts", withAPIUser(s, handlerAPIPosts))
+	mux.HandleFunc("POST /v1/users/{user}/posts/read", withAPIUser(s, handlerAPIMarkPostsRead))
+	mux.HandleFunc("POST /v1/users/{user}/posts/{id}/read", withAPIUser(s, handlerAPIMarkPostRead))
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, http.StatusNotFound, "not found")
+	})
+
+	return mux
+}
+
+// Serve the JSON API along with the rivers of users who opted in to publishing
+func handlerServe(s *state, cmd command) (err error) {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "address to listen on, a token is required unless it is a loopback one")
+	token := flags.String("token", s.cfg.APIToken, "bearer token required by the API, api_token from the config by default")
+	_, err = parseFlags(flags, cmd.args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if *token == "" && !isLoopbackAddr(*addr) {
+		return fmt.Errorf("an API token is required to listen on %s, set api_token or --token, or listen on a loopback address", *addr)
+	}
+
+	// WebSub callbacks are served by the agg listener only
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", apiAuth(*token, newAPIMux(s)))
+	mux.Handle("GET /publish/{user}", apiAuth(*token, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerPublishServe(s, w, r)
+	})))
+
+	if *token == "" {
+		log.Println("No API token set, anyone on this machine can act as any user")
+	}
+	log.Printf("Serving the API on %s", *addr)
+
+	server := &http.Server{
+		Addr: *addr,
+		Handler: mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+// Whether a listen address only accepts connections from this machine
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {return false}
+	if host == "localhost" {return true}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func handlerAPIUsers(s *state, w http.ResponseWriter, r *http.Request) {
+	users, err := s.db.GetUsers(r.Context())
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	out := make([]apiUser, 0, len(users))
+	for _, user := range users {
+		out = append(out, apiUser{Name: user.Name, CreatedAt: user.CreatedAt})
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+func handlerAPICreateUser(s *state, w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if !decodeJSON(w, r, &body) {return}
+
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		respondError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	user, err := s.db.CreateUser(r.Context(), database.CreateUserParams{
+		ID: uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Name: body.Name,
+	})
+	if isUniqueViolation(err) {
+		respondError(w, http.StatusConflict, "user already exists")
+		return
+	}
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, apiUser{Name: user.Name, CreatedAt: user.CreatedAt})
+}
+
+// ID of the user given by the user query parameter, feeds are named by the user's aliases
+func apiAliasUserID(s *state, w http.ResponseWriter, r *http.Request) (userID uuid.UUID, ok bool) {
+	name := r.URL.Query().Get("user")
+	if name == "" {return userID, true}
+
+	user, err := s.db.GetUser(r.Context(), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "user not found")
+		return userID, false
+	}
+	if err != nil {
+		respondInternalError(w, r, err)
+		return userID, false
+	}
+	return user.ID, true
+}
+
+func handlerAPIFeeds(s *state, w http.ResponseWriter, r *http.Request) {
+	userID, ok := apiAliasUserID(s, w, r)
+	if !ok {return}
+
+	feeds, err := s.db.GetFeedsForUser(r.Context(), userID)
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	out := make([]apiFeed, 0, len(feeds))
+	for _, feed := range feeds {
+		out = append(out, newAPIFeed(feed))
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+func handlerAPIFeed(s *state, w http.ResponseWriter, r *http.Request) {
+	feedID, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid feed ID")
+		return
+	}
+
+	userID, ok := apiAliasUserID(s, w, r)
+	if !ok {return}
+
+	feed, err := s.db.GetFeedByIDForUser(r.Context(), database.GetFeedByIDForUserParams{ID: int32(feedID), UserID: userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "feed not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, newAPIFeed(feed))
+}
+
+// Followed feeds of a user with their tags
+func handlerAPIFollows(s *state, w http.ResponseWriter, r *http.Request, user database.User) {
+	rows, err := s.db.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	// rows are repeated for every tag of a feed
+	follows := []apiFollow{}
+	byFeed := map[int32]int{}
+	for _, row := range rows {
+		idx, seen := byFeed[row.FeedID]
+		if !seen {
+			idx = len(follows)
+			byFeed[row.FeedID] = idx
+			follows = append(follows, apiFollow{FeedID: row.FeedID, Name: row.FeedName, URL: row.FeedUrl, Tags: []string{}})
+		}
+		if row.Tag.Valid {
+			follows[idx].Tags = append(follows[idx].Tags, row.Tag.String)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, follows)
+}
+
+// Follow a feed by URL, unknown feeds are added first like addfeed does
+func handlerAPICreateFollow(s *state, w http.ResponseWriter, r *http.Request, user database.User) {
+	var body struct {
+		URL string `json:"url"`
+		Name string `json:"name"`
+	}
+	if !decodeJSON(w, r, &body) {return}
+
+	body.URL = strings.TrimSpace(body.URL)
+	if body.URL == "" {
+		respondError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+
+	feed, err := s.db.GetFeed(r.Context(), body.URL)
+	if errors.Is(err, sql.ErrNoRows) {
+		if strings.TrimSpace(body.Name) == "" {
+			respondError(w, http.StatusBadRequest, "name is required for feeds that aren't added yet")
+			return
+		}
+		feed, err = s.db.CreateFeed(r.Context(), database.CreateFeedParams{
+			Name: strings.TrimSpace(body.Name),
+			Url: body.URL,
+			UserID: user.ID,
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+		})
+	}
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	follow, err := s.db.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feed.ID,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Alias: feedAlias(feed, body.Name),
+	})
+	if isUniqueViolation(err) {
+		respondError(w, http.StatusConflict, "feed is already followed")
+		return
+	}
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, apiFollow{FeedID: feed.ID, Name: follow.FeedName, URL: feed.Url, Tags: []string{}})
+}
+
+func handlerAPIDeleteFollow(s *state, w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, err := strconv.ParseInt(r.PathValue("feed"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid feed ID")
+		return
+	}
+
+	userID, ok := apiAliasUserID(s, w, r)
+	if !ok {return}
+
+	feed, err := s.db.GetFeedByIDForUser(r.Context(), database.GetFeedByIDForUserParams{ID: int32(feedID), UserID: userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "feed not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	err = s.db.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{
+		UserID: user.ID,
+		Url: feed.Url,
+	})
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Browse posts like the browse command, pages continue after the last post of the previous one
+func handlerAPIPosts(s *state, w http.ResponseWriter, r *http.Request, user database.User) {
+	query := r.URL.Query()
+
+	limit := defaultAPIPageSize
+	if query.Has("limit") {
+		var err error
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || limit <= 0 || limit > maxAPIPageSize {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAPIPageSize))
+			return
+		}
+	}
+
+	params := database.GetPostsForUserParams{
+		UserID: user.ID,
+		UnreadOnly: query.Get("unread") == "true",
+		StarredOnly: query.Get("starred") == "true",
+		Tag: normalizeTag(query.Get("tag")),
+		Feed: query.Get("feed"),
+		SortBy: query.Get("sort"),
+	}
+	if params.SortBy == "" {
+		params.SortBy = "published"
+	}
+	if params.SortBy != "published" && params.SortBy != "fetched" {
+		respondError(w, http.StatusBadRequest, "sort must be published or fetched")
+		return
+	}
+	if query.Has("after") {
+		after, err := strconv.ParseInt(query.Get("after"), 10, 32)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid after")
+			return
+		}
+		params.After = int32(after)
+	}
+	for name, param := range map[string]*sql.NullTime{"since": &params.Since, "until": &params.Until} {
+		if !query.Has(name) {continue}
+		parsed, err := parseDate(query.Get(name))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s: %v", name, err))
+			return
+		}
+		*param = sql.NullTime{Time: parsed, Valid: true}
+	}
+
+	var filters postFilters
+	if query.Get("unfiltered") != "true" {
+		var err error
+		filters, err = loadFilters(s, user.ID)
+		if err != nil {
+			respondInternalError(w, r, err)
+			return
+		}
+	}
+
+	fetch := func(limit, offset int32) ([]database.GetPostsForUserRow, error) {
+		pageParams := params
+		pageParams.Limit, pageParams.Offset = limit, offset
+		return s.db.GetPostsForUser(r.Context(), pageParams)
+	}
+	posts, _, err := getFilteredPosts(fetch, int32(limit), 0, filters)
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	page := apiPostPage{Posts: make([]apiPost, 0, len(posts))}
+	for _, post := range posts {
+		page.Posts = append(page.Posts, newAPIPost(post))
+	}
+	if len(posts) == limit {
+		page.NextAfter = &posts[len(posts)-1].ID
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+func handlerAPIMarkPostRead(s *state, w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, err := parsePostID(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err = s.db.GetPostForUser(r.Context(), database.GetPostForUserParams{ID: postID, UserID: user.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "post not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	err = s.db.MarkPostRead(r.Context(), database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: postID,
+	})
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Mark posts read in bulk like the mark-read command
+func handlerAPIMarkPostsRead(s *state, w http.ResponseWriter, r *http.Request, user database.User) {
+	var body struct {
+		FeedURL string `json:"feed_url"`
+		Before string `json:"before"`
+		All bool `json:"all"`
+	}
+	if !decodeJSON(w, r, &body) {return}
+
+	if body.FeedURL == "" && body.Before == "" && !body.All {
+		respondError(w, http.StatusBadRequest, "feed_url, before or all is required")
+		return
+	}
+
+	params := database.MarkPostsReadParams{
+		UserID: user.ID,
+		FeedUrl: body.FeedURL,
+	}
+	if body.Before != "" {
+		before, err := parseDate(body.Before)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid before: "+err.Error())
+			return
+		}
+		params.Before = sql.NullTime{Time: before, Valid: true}
+	}
+
+	marked, err := s.db.MarkPostsRead(r.Context(), params)
+	if err != nil {
+		respondInternalError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]int64{"marked": marked})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/DIVIgor/gator/internal/readability"
+	"github.com/DIVIgor/gator/internal/requests"
+)
+
+const maxArticleSize int64 = 5 << 20
+
+
+// Download a post page and extract the main article content from it
+func fetchArticle(client *requests.Client, ctx context.Context, postURL string) (content string, err error) {
+	if len(postURL) == 0 {
+		err = errors.New("no URL provided")
+		return
+	}
+
+	resp, err := client.MakeRequest(ctx, "GET", postURL, nil)
+	if err != nil {return}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return content, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxArticleSize))
+	if err != nil {return}
+
+	return readability.Extract(string(page))
+}
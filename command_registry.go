@@ -1,6 +1,14 @@
 package main
 
-import "errors"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
 
 
 type command struct {
@@ -8,6 +16,14 @@ type command struct {
     args []string
 }
 
+// Options accepted by every command
+type globalOptions struct {
+	output string
+	noPager bool
+	// layout of dates in the output, set from the config
+	timeFormat string
+}
+
 type commands struct {
 	cmdList map[string]func(*state, command) error
 }
@@ -26,4 +42,65 @@ func (c *commands) run(s *state, cmd command) (err error) {
 	}
 
 	return command(s, cmd)
-}
\ No newline at end of file
+}
+
+// Parse command flags allowing them to be mixed with positional arguments
+func parseFlags(flags *flag.FlagSet, args []string) (positional []string, err error) {
+	flags.SetOutput(io.Discard)
+	for {
+		err = flags.Parse(args)
+		if err != nil {return}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, err
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Take global options out of the arguments. They go before the command name,
+// the arguments from the command name or a "--" on belong to the command
+func parseGlobalFlags(args []string) (opts globalOptions, rest []string, err error) {
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		if arg == "--" {
+			rest = args[idx+1:]
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			rest = args[idx:]
+			break
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != "output" && name != "no-pager" {
+			return opts, rest, fmt.Errorf("unknown global option: %s", arg)
+		}
+		if name == "no-pager" {
+			opts.noPager = true
+			if hasValue {
+				opts.noPager, err = strconv.ParseBool(value)
+				if err != nil {
+					return opts, rest, fmt.Errorf("invalid boolean value %q for %s", value, arg)
+				}
+			}
+			continue
+		}
+		if !hasValue {
+			if idx+1 == len(args) {
+				return opts, rest, fmt.Errorf("flag needs an argument: %s", arg)
+			}
+			idx++
+			value = args[idx]
+		}
+		opts.output = strings.ToLower(value)
+	}
+
+	if opts.output != "" && !slices.Contains(outputFormats, opts.output) {
+		return opts, rest, fmt.Errorf("output must be one of %s, got %q", strings.Join(outputFormats, ", "), opts.output)
+	}
+
+	return opts, rest, err
+}
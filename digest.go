@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DIVIgor/gator/internal/database"
+	"github.com/DIVIgor/gator/internal/htmltext"
+	"github.com/DIVIgor/gator/internal/mailer"
+	"github.com/google/uuid"
+)
+
+// Posts in a single digest at most
+const digestPostLimit int32 = 200
+const digestSummaryLength int = 300
+
+// Digest frequencies and the time between digests
+var digestPeriods = map[string]time.Duration{
+	"daily": 24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+var digestHTML = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; line-height: 1.5; color: #222;">
+<h1 style="font-size: 1.3em;">{{.Count}} new posts since {{.Since}}</h1>
+{{- range .Feeds}}
+<h2 style="font-size: 1.1em; border-bottom: 1px solid #ddd;">{{.Name}}</h2>
+{{- range .Posts}}
+<p><a href="{{.URL}}"><strong>{{.Title}}</strong></a><br>
+<small style="color: #666;">{{.Published}}</small><br>
+{{.Summary}}</p>
+{{- end}}
+{{- end}}
+<p style="color: #888;"><small>Sent by gator to {{.UserName}}. Run <code>gator digest off</code> to stop these emails.</small></p>
+</body>
+</html>
+`))
+
+
+// Digest schedule of a user
+type digestTarget struct {
+	userID uuid.UUID
+	userName string
+	email string
+	frequency string
+	lastSentAt sql.NullTime
+	// the last post of a digest cut short by the limit
+	lastSentPostID int32
+}
+
+// Unread posts of a user grouped by feed
+type digest struct {
+	UserName string
+	Since string
+	Count int
+	Feeds []digestFeed
+	// fetching time and ID of the post the digest covers posts up to, the ID is 0 if it covers them all
+	until time.Time
+	untilPostID int32
+}
+
+type digestFeed struct {
+	Name string
+	Posts []digestPost
+}
+
+type digestPost struct {
+	Title string
+	URL string
+	Published string
+	Summary string
+}
+
+
+// Posts go into the digest if they were fetched after the last digest,
+// the first digest covers one period
+func (t digestTarget) since(now time.Time) time.Time {
+	if t.lastSentAt.Valid {
+		return t.lastSentAt.Time
+	}
+	return now.Add(-digestPeriods[t.frequency])
+}
+
+func (t digestTarget) due(now time.Time) bool {
+	return !t.lastSentAt.Valid || !t.lastSentAt.Time.Add(digestPeriods[t.frequency]).After(now)
+}
+
+// Collect unread posts fetched since the last digest, muted posts are left out
+func buildDigest(s *state, target digestTarget, now time.Time) (d digest, err error) {
+	filters, err := loadFilters(s, target.userID)
+	if err != nil {
+		return d, fmt.Errorf("couldn't load filter rules: %w", err)
+	}
+
+	since := target.since(now)
+	posts, err := s.db.GetDigestPosts(context.Background(), database.GetDigestPostsParams{
+		UserID: target.userID,
+		Since: since,
+		AfterID: target.lastSentPostID,
+		Limit: digestPostLimit,
+	})
+	if err != nil {
+		return d, fmt.Errorf("couldn't get posts: %w", err)
+	}
+
+	d = digest{UserName: target.userName, Since: since.Format(s.options.timeFormat), until: now}
+	// posts past the limit are left to the next digest, which continues
+	// after the last post as others may have been fetched at the same time
+	if int32(len(posts)) == digestPostLimit {
+		last := posts[len(posts)-1]
+		d.until, d.untilPostID = last.CreatedAt, last.ID
+	}
+
+	slices.SortStableFunc(posts, func(a, b database.GetDigestPostsRow) int {
+		if a.FeedName != b.FeedName {
+			return strings.Compare(a.FeedName, b.FeedName)
+		}
+		return b.PublishedAt.Compare(a.PublishedAt)
+	})
+	for _, post := range posts {
+		if filters.hides(post.FeedID, post.Title, post.Description.String) {continue}
+
+		if len(d.Feeds) == 0 || d.Feeds[len(d.Feeds)-1].Name != post.FeedName {
+			d.Feeds = append(d.Feeds, digestFeed{Name: post.FeedName})
+		}
+		feed := &d.Feeds[len(d.Feeds)-1]
+		feed.Posts = append(feed.Posts, digestPost{
+			Title: post.Title,
+			URL: post.Url,
+			Published: post.PublishedAt.Format(s.options.timeFormat),
+			Summary: templateTruncate(digestSummaryLength, htmltext.Strip(post.Description.String)),
+		})
+		d.Count++
+	}
+
+	return d, err
+}
+
+// Render the digest as an email with plain text and HTML versions
+func (d digest) message(from, to string) (msg mailer.Message, err error) {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d new posts since %s\n", d.Count, d.Since)
+	for _, feed := range d.Feeds {
+		fmt.Fprintf(&text, "\n== %s ==\n", feed.Name)
+		for _, post := range feed.Posts {
+			fmt.Fprintf(&text, "\n* %s\n  %s\n  %s\n", post.Title, post.URL, post.Published)
+			if post.Summary != "" {
+				fmt.Fprintf(&text, "  %s\n", post.Summary)
+			}
+		}
+	}
+	fmt.Fprintf(&text, "\nSent by gator to %s. Run `gator digest off` to stop these emails.\n", d.UserName)
+
+	var html bytes.Buffer
+	err = digestHTML.Execute(&html, d)
+	if err != nil {return}
+
+	return mailer.Message{
+		From: from,
+		To: []string{to},
+		Subject: fmt.Sprintf("Gator digest: %d new posts", d.Count),
+		Date: time.Now(),
+		Text: text.String(),
+		HTML: html.String(),
+	}, err
+}
+
+// Build and send a digest, or print it on a dry run.
+// Empty digests aren't sent but still count as sent.
+func sendDigest(s *state, target digestTarget, dryRun bool) (err error) {
+	now := time.Now().UTC()
+	d, err := buildDigest(s, target, now)
+	if err != nil {return}
+
+	from := "gator@localhost"
+	if s.cfg.SMTP != nil && s.cfg.SMTP.From != "" {
+		from = s.cfg.SMTP.From
+	}
+	msg, err := d.message(from, target.email)
+	if err != nil {
+		return fmt.Errorf("couldn't render digest: %w", err)
+	}
+
+	if dryRun {
+		fmt.Println("From:", msg.From)
+		fmt.Println("To:", target.email)
+		fmt.Println("Subject:", msg.Subject)
+		fmt.Println()
+		fmt.Print(msg.Text)
+		return err
+	}
+
+	if d.Count > 0 {
+		if s.cfg.SMTP == nil || s.cfg.SMTP.Host == "" {
+			return errors.New("no SMTP server configured, set smtp in the config")
+		}
+		err = mailer.Send(mailer.Options{
+			Host: s.cfg.SMTP.Host,
+			Port: s.cfg.SMTP.Port,
+			Username: s.cfg.SMTP.Username,
+			Password: s.cfg.SMTP.Password,
+			ImplicitTLS: s.cfg.SMTP.ImplicitTLS,
+		}, msg)
+		if err != nil {
+			return fmt.Errorf("couldn't send digest to %s: %w", target.email, err)
+		}
+	}
+
+	return s.db.MarkDigestSent(context.Background(), database.MarkDigestSentParams{
+		UserID: target.userID,
+		LastSentAt: sql.NullTime{Time: d.until, Valid: true},
+		LastSentPostID: d.untilPostID,
+	})
+}
+
+// Send digests of every user whose schedule is due
+func sendDueDigests(s *state) (sent int, err error) {
+	schedules, err := s.db.GetDigestSchedules(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get digest schedules: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for _, schedule := range schedules {
+		target := digestTarget{
+			userID: schedule.UserID,
+			userName: schedule.UserName,
+			email: schedule.Email,
+			frequency: schedule.Frequency,
+			lastSentAt: schedule.LastSentAt,
+			lastSentPostID: schedule.LastSentPostID,
+		}
+		if !target.due(now) {continue}
+
+		// a failed digest shouldn't hold back the others
+		err := sendDigest(s, target, false)
+		if err != nil {
+			log.Printf("Couldn't send the digest of %s: %v", schedule.UserName, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, err
+}
+
+// Schedule, preview and send email digests of unread posts
+func handlerDigest(s *state, cmd command, user database.User) (err error) {
+	if len(cmd.args) > 0 && !strings.HasPrefix(cmd.args[0], "-") {
+		switch cmd.args[0] {
+		case "schedule":
+			return scheduleDigest(s, cmd, user)
+		case "off":
+			removed, err := s.db.DeleteDigestSchedule(context.Background(), user.ID)
+			if err != nil {
+				return fmt.Errorf("couldn't remove digest schedule: %w", err)
+			}
+			if removed == 0 {
+				return errors.New("digests aren't scheduled")
+			}
+			fmt.Println("Digests turned off")
+			return err
+		default:
+			return fmt.Errorf("unknown %s subcommand: %s", cmd.name, cmd.args[0])
+		}
+	}
+
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the digest instead of sending it")
+	due := flags.Bool("due", false, "send the digest only if it is due, e.g. from cron")
+	_, err = parseFlags(flags, cmd.args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	schedule, err := s.db.GetDigestSchedule(context.Background(), user.ID)
+	target := digestTarget{
+		userID: user.ID,
+		userName: user.Name,
+		email: schedule.Email,
+		frequency: schedule.Frequency,
+		lastSentAt: schedule.LastSentAt,
+		lastSentPostID: schedule.LastSentPostID,
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		if !*dryRun {
+			return fmt.Errorf("digests aren't scheduled, use %s schedule <email> first", cmd.name)
+		}
+		// a preview works without a schedule
+		target.email, target.frequency, err = "<not scheduled>", "daily", nil
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get digest schedule: %w", err)
+	}
+
+	// other users' digests are only sent by agg
+	now := time.Now().UTC()
+	if *due && !target.due(now) {
+		fmt.Println("The next digest is due at", target.lastSentAt.Time.Add(digestPeriods[target.frequency]).Format(s.options.timeFormat))
+		return err
+	}
+
+	err = sendDigest(s, target, *dryRun)
+	if err != nil {return}
+	if !*dryRun {
+		fmt.Println("Digest sent to", target.email)
+	}
+
+	return err
+}
+
+func scheduleDigest(s *state, cmd command, user database.User) (err error) {
+	if len(cmd.args) < 2 {
+		return fmt.Errorf("%s schedule has not enough arguments", cmd.name)
+	}
+
+	address, err := mail.ParseAddress(cmd.args[1])
+	if err != nil {
+		return fmt.Errorf("invalid email address: %w", err)
+	}
+	frequency := "daily"
+	if len(cmd.args) > 2 {
+		frequency = strings.ToLower(cmd.args[2])
+	}
+	if _, ok := digestPeriods[frequency]; !ok {
+		return fmt.Errorf("frequency must be daily or weekly, got %q", frequency)
+	}
+
+	schedule, err := s.db.UpsertDigestSchedule(context.Background(), database.UpsertDigestScheduleParams{
+		UserID: user.ID,
+		Email: address.Address,
+		Frequency: frequency,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't schedule digests: %w", err)
+	}
+
+	fmt.Printf("Sending %s digests to %s\n", schedule.Frequency, schedule.Email)
+	return err
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DIVIgor/gator/internal/database"
+	"github.com/DIVIgor/gator/internal/htmltext"
+)
+
+const defaultExportLimit int = 500
+const exportSummaryLength int = 500
+
+var exportHTML = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Period}}</p>
+{{- range .Feeds}}
+<h2>{{.Name}}</h2>
+{{- range .Posts}}
+<article>
+<h3>{{if .URL}}<a href="{{.URL}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</h3>
+<p><time datetime="{{.Datetime}}">{{.Published}}</time></p>
+{{- if .SummaryHTML}}
+<div>{{.SummaryHTML}}</div>
+{{- end}}
+</article>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// Characters with a meaning in Markdown text
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`,
+)
+
+
+// Posts grouped by feed for export
+type exportDocument struct {
+	Title string
+	Period string
+	Feeds []exportFeed
+}
+
+type exportFeed struct {
+	Name string
+	Posts []exportPost
+}
+
+type exportPost struct {
+	Title string
+	// empty unless it's a web link
+	URL string
+	Published string
+	Datetime string
+	Summary string
+	SummaryHTML template.HTML
+}
+
+
+// Export data in other formats
+func handlerExport(s *state, cmd command, user database.User) (err error) {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("%s has not enough arguments", cmd.name)
+	}
+
+	switch cmd.args[0] {
+	case "posts":
+		return exportPosts(s, cmd, user)
+	case "mbox", "maildir":
+		return exportMailbox(s, cmd, user)
+	default:
+		return fmt.Errorf("unknown %s subcommand: %s", cmd.name, cmd.args[0])
+	}
+}
+
+// Write posts of followed feeds as a Markdown or HTML reading list
+func exportPosts(s *state, cmd command, user database.User) (err error) {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	format := flags.String("format", "markdown", "document format: markdown or html")
+	since := flags.String("since", "7d", "posts published since a date (2006-01-02) or a period ago (e.g. 7d)")
+	until := flags.String("until", "", "posts published before a date (2006-01-02) or a period ago (e.g. 7d)")
+	tag := flags.String("tag", "", "export only feeds with this tag")
+	starred := flags.Bool("starred", false, "export only starred posts")
+	title := flags.String("title", "Reading list", "document title")
+	outFile := flags.String("out", "", "write the document to a file instead of stdout")
+	limit := flags.Int("limit", defaultExportLimit, "maximum number of posts")
+	_, err = parseFlags(flags, cmd.args[1:])
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if *format != "markdown" && *format != "html" {
+		return fmt.Errorf("format must be markdown or html, got %q", *format)
+	}
+	if *limit <= 0 {
+		return fmt.Errorf("invalid limit: %d", *limit)
+	}
+
+	params := database.GetPostsForUserParams{
+		UserID: user.ID,
+		StarredOnly: *starred,
+		Tag: normalizeTag(*tag),
+		SortBy: "published",
+		Limit: int32(*limit),
+	}
+	period := ""
+	if *since != "" {
+		sinceTime, err := parseDate(*since)
+		if err != nil {return err}
+		params.Since = sql.NullTime{Time: sinceTime, Valid: true}
+		period = "From " + sinceTime.Format(time.DateOnly)
+	}
+	if *until != "" {
+		untilTime, err := parseDate(*until)
+		if err != nil {return err}
+		params.Until = sql.NullTime{Time: untilTime, Valid: true}
+		period = strings.TrimSpace(period + " until " + untilTime.Format(time.DateOnly))
+	}
+
+	posts, err := getExportPosts(s, params)
+	if err != nil {
+		return fmt.Errorf("couldn't get posts: %w", err)
+	}
+
+	doc := newExportDocument(*title, period, s.options.timeFormat, posts)
+	var out []byte
+	if *format == "markdown" {
+		out = doc.markdown()
+	} else {
+		var buf bytes.Buffer
+		err = exportHTML.Execute(&buf, doc)
+		if err != nil {return}
+		out = buf.Bytes()
+	}
+
+	if *outFile == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+
+	err = os.WriteFile(*outFile, out, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't write %s: %w", *outFile, err)
+	}
+	fmt.Printf("Exported %d posts to %s\n", len(posts), *outFile)
+
+	return err
+}
+
+// Get posts to export, starred ones are included even if their feed isn't followed anymore
+func getExportPosts(s *state, params database.GetPostsForUserParams) (posts []database.GetPostsForUserRow, err error) {
+	if !params.StarredOnly {
+		return s.db.GetPostsForUser(context.Background(), params)
+	}
+
+	rows, err := s.db.GetStarredPostsForExport(context.Background(), database.GetStarredPostsForExportParams{
+		UserID: params.UserID,
+		Tag: params.Tag,
+		Since: params.Since,
+		Until: params.Until,
+		Limit: params.Limit,
+	})
+	if err != nil {return}
+
+	for _, row := range rows {
+		posts = append(posts, database.GetPostsForUserRow{
+			ID: row.ID,
+			Title: row.Title,
+			Url: row.Url,
+			Description: row.Description,
+			PublishedAt: row.PublishedAt,
+			FeedID: row.FeedID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Content: row.Content,
+			ReadAt: row.ReadAt,
+			FeedName: row.FeedName,
+			Guid: row.Guid,
+		})
+	}
+	return posts, err
+}
+
+// Group posts by feed name, posts keep their order within a feed
+func newExportDocument(title, period, timeFormat string, posts []database.GetPostsForUserRow) (doc exportDocument) {
+	doc = exportDocument{Title: title, Period: period}
+
+	feeds := map[string]int{}
+	for _, post := range posts {
+		idx, ok := feeds[post.FeedName]
+		if !ok {
+			idx = len(doc.Feeds)
+			feeds[post.FeedName] = idx
+			doc.Feeds = append(doc.Feeds, exportFeed{Name: post.FeedName})
+		}
+
+		// descriptions are sanitized again in case they were saved by an older version
+		summary := htmltext.Sanitize(post.Description.String)
+		// links of other schemes could run scripts or local handlers once rendered
+		link := ""
+		if isWebURL(post.Url) {
+			link = post.Url
+		}
+		doc.Feeds[idx].Posts = append(doc.Feeds[idx].Posts, exportPost{
+			Title: post.Title,
+			URL: link,
+			Published: post.PublishedAt.Format(timeFormat),
+			Datetime: post.PublishedAt.Format(time.RFC3339),
+			Summary: templateTruncate(exportSummaryLength, htmltext.Strip(summary)),
+			SummaryHTML: template.HTML(summary),
+		})
+	}
+
+	sort.SliceStable(doc.Feeds, func(i, j int) bool {
+		return strings.ToLower(doc.Feeds[i].Name) < strings.ToLower(doc.Feeds[j].Name)
+	})
+	return doc
+}
+
+func (doc exportDocument) markdown() []byte {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# %s\n", markdownEscaper.Replace(doc.Title))
+	if doc.Period != "" {
+		fmt.Fprintf(&out, "\n%s\n", doc.Period)
+	}
+
+	for _, feed := range doc.Feeds {
+		fmt.Fprintf(&out, "\n## %s\n", markdownEscaper.Replace(feed.Name))
+		for _, post := range feed.Posts {
+			title := markdownEscaper.Replace(post.Title)
+			if post.URL != "" {
+				// parentheses would end the link destination early
+				link := strings.NewReplacer("(", "%28", ")", "%29", " ", "%20").Replace(post.URL)
+				title = fmt.Sprintf("[%s](%s)", title, link)
+			}
+			fmt.Fprintf(&out, "\n### %s\n\n*%s*\n", title, post.Published)
+			if post.Summary != "" {
+				fmt.Fprintf(&out, "\n%s\n", markdownEscaper.Replace(post.Summary))
+			}
+		}
+	}
+
+	return []byte(out.String())
+}
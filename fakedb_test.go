@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/DIVIgor/gator/internal/database"
+)
+
+// Answers a generated query by its sqlc name with the result columns and rows
+type fakeQuery func(args []driver.NamedValue) (rows [][]driver.Value, err error)
+
+// In-memory stand-in for Postgres that answers the queries a test expects
+type fakeDB struct {
+	mu sync.Mutex
+	queries map[string]fakeQuery
+	calls map[string][]([]driver.NamedValue)
+}
+
+var queryName = regexp.MustCompile(`-- name: (\w+)`)
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs = map[string]*fakeDB{}
+	registerFakeDriver sync.Once
+)
+
+
+// Queries backed by a fake database, unexpected queries fail the test
+func newFakeQueries(t *testing.T, queries map[string]fakeQuery) (*database.Queries, *fakeDB) {
+	registerFakeDriver.Do(func() {
+		sql.Register("gatorfake", fakeDriver{})
+	})
+
+	fake := &fakeDB{queries: queries, calls: map[string][]([]driver.NamedValue){}}
+	fakeDBsMu.Lock()
+	fakeDBs[t.Name()] = fake
+	fakeDBsMu.Unlock()
+
+	db, err := sql.Open("gatorfake", t.Name())
+	if err != nil {t.Fatal(err)}
+	t.Cleanup(func() {
+		db.Close()
+		fakeDBsMu.Lock()
+		delete(fakeDBs, t.Name())
+		fakeDBsMu.Unlock()
+	})
+
+	return database.New(db), fake
+}
+
+// Arguments of the calls of a query so far
+func (f *fakeDB) callsOf(name string) [][]driver.NamedValue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[name]
+}
+
+func (f *fakeDB) run(query string, args []driver.NamedValue) (rows [][]driver.Value, err error) {
+	match := queryName.FindStringSubmatch(query)
+	if match == nil {
+		return nil, fmt.Errorf("query without a name: %s", query)
+	}
+
+	f.mu.Lock()
+	f.calls[match[1]] = append(f.calls[match[1]], args)
+	answer, ok := f.queries[match[1]]
+	f.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unexpected query %s", match[1])
+	}
+
+	return answer(args)
+}
+
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+	fake, ok := fakeDBs[name]
+	if !ok {
+		return nil, fmt.Errorf("no fake database %s", name)
+	}
+	return fakeConn{fake}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements aren't supported")
+}
+
+func (c fakeConn) Close() error {return nil}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions aren't supported")
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.db.run(query, args)
+	if err != nil {return nil, err}
+	return &fakeRows{rows: rows}, nil
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.db.run(query, args)
+	if err != nil {return nil, err}
+	return driver.RowsAffected(len(rows)), nil
+}
+
+// Rows of a fake result, the columns are only counted
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {return nil}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error {return nil}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {return io.EOF}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
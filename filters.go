@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DIVIgor/gator/internal/database"
+	"github.com/DIVIgor/gator/internal/htmltext"
+	"github.com/google/uuid"
+)
+
+// A filter rule ready to be matched against posts
+type postFilter struct {
+	feedID sql.NullInt32
+	exclude bool
+	field string
+	pattern *regexp.Regexp
+}
+
+// Filter rules of a single user
+type postFilters []postFilter
+
+
+// Compile a rule, keywords are matched case-insensitively as plain text
+func newPostFilter(rule database.FilterRule) (filter postFilter, err error) {
+	expr := regexp.QuoteMeta(rule.Pattern)
+	if rule.IsRegex {
+		expr = rule.Pattern
+	}
+
+	pattern, err := regexp.Compile("(?i)" + expr)
+	if err != nil {
+		return filter, fmt.Errorf("invalid pattern %q: %w", rule.Pattern, err)
+	}
+
+	return postFilter{
+		feedID: rule.FeedID,
+		exclude: rule.Action == "exclude",
+		field: rule.Field,
+		pattern: pattern,
+	}, err
+}
+
+func compileFilters(rules []database.FilterRule) (filters postFilters, err error) {
+	for _, rule := range rules {
+		filter, err := newPostFilter(rule)
+		if err != nil {return nil, err}
+		filters = append(filters, filter)
+	}
+	return filters, err
+}
+
+// Get all the filter rules of a user
+func loadFilters(s *state, userID uuid.UUID) (filters postFilters, err error) {
+	rows, err := s.db.GetFilterRulesForUser(context.Background(), userID)
+	if err != nil {return}
+
+	rules := make([]database.FilterRule, 0, len(rows))
+	for _, row := range rows {
+		rules = append(rules, database.FilterRule{
+			ID: row.ID,
+			UserID: row.UserID,
+			FeedID: row.FeedID,
+			Action: row.Action,
+			Field: row.Field,
+			Pattern: row.Pattern,
+			IsRegex: row.IsRegex,
+			MarkRead: row.MarkRead,
+		})
+	}
+
+	return compileFilters(rules)
+}
+
+func (f postFilter) matches(title, description string) bool {
+	switch f.field {
+	case "title":
+		return f.pattern.MatchString(title)
+	case "description":
+		return f.pattern.MatchString(description)
+	default:
+		return f.pattern.MatchString(title) || f.pattern.MatchString(description)
+	}
+}
+
+// Check if a post is muted: it matches an exclude rule, or there are include
+// rules for its feed and none of them matches
+func (filters postFilters) hides(feedID int32, title, description string) bool {
+	description = htmltext.Strip(description)
+	hasIncludes, included := false, false
+
+	for _, filter := range filters {
+		if filter.feedID.Valid && filter.feedID.Int32 != feedID {continue}
+
+		matched := filter.matches(title, description)
+		if filter.exclude && matched {return true}
+		if !filter.exclude {
+			hasIncludes = true
+			included = included || matched
+		}
+	}
+
+	return hasIncludes && !included
+}
+
+// Mark new posts read for users whose ingestion rules mute them
+func applyIngestFilters(s *state, feedID int32, posts []database.Post) (err error) {
+	if len(posts) == 0 {return}
+
+	rules, err := s.db.GetIngestFilterRulesForFeed(context.Background(), feedID)
+	if err != nil {return}
+
+	rulesByUser := map[uuid.UUID][]database.FilterRule{}
+	for _, rule := range rules {
+		rulesByUser[rule.UserID] = append(rulesByUser[rule.UserID], rule)
+	}
+
+	for userID, userRules := range rulesByUser {
+		filters, err := compileFilters(userRules)
+		if err != nil {return err}
+
+		for _, post := range posts {
+			if !filters.hides(post.FeedID, post.Title, post.Description.String) {continue}
+
+			err = s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+				UserID: userID,
+				PostID: post.ID,
+			})
+			if err != nil {return err}
+		}
+	}
+
+	return err
+}
+
+// Manage filter rules: filter add|list|rm
+func handlerFilter(s *state, cmd command, user database.User) (err error) {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("%s has not enough arguments", cmd.name)
+	}
+
+	subcommand := command{name: cmd.name + " " + cmd.args[0], args: cmd.args[1:]}
+	switch cmd.args[0] {
+	case "add":
+		return handlerFilterAdd(s, subcommand, user)
+	case "list":
+		return handlerFilterList(s, subcommand, user)
+	case "rm":
+		return handlerFilterRemove(s, subcommand, user)
+	default:
+		return fmt.Errorf("unknown %s subcommand: %s", cmd.name, cmd.args[0])
+	}
+}
+
+// Add a rule: filter add include|exclude <pattern> [--regex] [--field title|description|any] [--feed URL] [--ingest]
+func handlerFilterAdd(s *state, cmd command, user database.User) (err error) {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	isRegex := flags.Bool("regex", false, "treat the pattern as a regular expression")
+	field := flags.String("field", "any", "field to match: title, description or any")
+	feedURL := flags.String("feed", "", "apply the rule to the feed with this URL only")
+	ingest := flags.Bool("ingest", false, "mark muted posts read as soon as they are collected")
+	args, err := parseFlags(flags, cmd.args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if len(args) < 2 {
+		return fmt.Errorf("%s has not enough arguments", cmd.name)
+	}
+
+	action, pattern := args[0], strings.Join(args[1:], " ")
+	if action != "include" && action != "exclude" {
+		return fmt.Errorf("rule action must be include or exclude, got %q", action)
+	}
+	if *field != "title" && *field != "description" && *field != "any" {
+		return fmt.Errorf("rule field must be title, description or any, got %q", *field)
+	}
+
+	params := database.CreateFilterRuleParams{
+		UserID: user.ID,
+		Action: action,
+		Field: *field,
+		Pattern: pattern,
+		IsRegex: *isRegex,
+		MarkRead: *ingest,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	if *feedURL != "" {
+		feed, err := s.db.GetFeed(context.Background(), *feedURL)
+		if err != nil {
+			return fmt.Errorf("couldn't get feed: %w", err)
+		}
+		params.FeedID = sql.NullInt32{Int32: feed.ID, Valid: true}
+	}
+
+	// reject invalid patterns before saving them
+	_, err = newPostFilter(database.FilterRule{Pattern: params.Pattern, IsRegex: params.IsRegex})
+	if err != nil {return}
+
+	rule, err := s.db.CreateFilterRule(context.Background(), params)
+	if err != nil {
+		return fmt.Errorf("couldn't create filter rule: %w", err)
+	}
+
+	fmt.Println("Filter rule", rule.ID, "created")
+
+	return err
+}
+
+// Print the filter rules of the current user
+func handlerFilterList(s *state, cmd command, user database.User) (err error) {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	format := addFormatFlag(flags)
+	_, err = parseFlags(flags, cmd.args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	rules, err := s.db.GetFilterRulesForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get filter rules: %w", err)
+	}
+
+	if customOutput(s, "filters", *format) {
+		list := newListing("id", "action", "pattern", "regex", "field", "feed_url", "ingest")
+		for _, rule := range rules {
+			list.add(rule.ID, rule.Action, rule.Pattern, rule.IsRegex, rule.Field, rule.FeedUrl, rule.MarkRead)
+		}
+		return writeListing(s, "filters", *format, list)
+	}
+
+	if len(rules) == 0 {
+		fmt.Println("No filter rules")
+		return err
+	}
+
+	fmt.Println("Your filter rules:")
+	for _, rule := range rules {
+		kind := "keyword"
+		if rule.IsRegex {
+			kind = "regex"
+		}
+		scope := "all feeds"
+		if rule.FeedUrl.Valid {
+			scope = rule.FeedUrl.String
+		}
+
+		line := fmt.Sprintf("* %d: %s %s %q in %s of %s", rule.ID, rule.Action, kind, rule.Pattern, rule.Field, scope)
+		if rule.MarkRead {
+			line += " (applied at ingestion)"
+		}
+		fmt.Println(line)
+	}
+
+	return err
+}
+
+// Remove a filter rule by its ID
+func handlerFilterRemove(s *state, cmd command, user database.User) (err error) {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("%s has not enough arguments", cmd.name)
+	}
+
+	ruleID, err := strconv.ParseInt(cmd.args[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid rule ID %q, see %s list for the IDs: %w", cmd.args[0], strings.Fields(cmd.name)[0], err)
+	}
+
+	removed, err := s.db.DeleteFilterRule(context.Background(), database.DeleteFilterRuleParams{
+		ID: int32(ruleID),
+		UserID: user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't remove filter rule: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("filter rule %d not found", ruleID)
+	}
+
+	fmt.Println("Filter rule", ruleID, "removed")
+
+	return err
+}
+
+// Get a page of posts to browse
+type postPager func(limit, offset int32) ([]database.GetPostsForUserRow, error)
+
+// Get posts skipping the muted ones, fetching more pages as needed.
+// Returns the offset to continue from.
+func getFilteredPosts(fetch postPager, limit, offset int32, filters postFilters) (posts []database.GetPostsForUserRow, nextOffset int32, err error) {
+	for int32(len(posts)) < limit {
+		page, err := fetch(limit, offset)
+		if err != nil {return nil, offset, err}
+
+		for _, post := range page {
+			offset++
+			if filters.hides(post.FeedID, post.Title, post.Description.String) {continue}
+			posts = append(posts, post)
+			if int32(len(posts)) == limit {break}
+		}
+
+		if int32(len(page)) < limit {break}
+	}
+
+	return posts, offset, err
+}
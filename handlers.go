@@ -1,23 +1,41 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/DIVIgor/gator/internal/config"
 	"github.com/DIVIgor/gator/internal/database"
+	"github.com/DIVIgor/gator/internal/htmltext"
 	"github.com/google/uuid"
 )
 
 
-const outputTimeFormat string = "02-Jan-2006 at 15:04"
+const defaultTimeFormat string = "02-Jan-2006 at 15:04"
+const defaultTerminalWidth int = 80
 const printDelimiter string = "=============================================================================================================="
 
 
+// Get the terminal width from the environment for wrapping text
+func terminalWidth() int {
+    width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+    if err != nil || width <= 0 {
+        return defaultTerminalWidth
+    }
+    return width
+}
+
 // set current user using an argument from CLI
 func handlerLogin(s *state, cmd command) (err error) {
     if len(cmd.args) == 0 {
@@ -67,9 +85,24 @@ func handlerRegister(s *state, cmd command) (err error) {
 
 // get and print all the registered users from the DB
 func handlerUsers(s *state, cmd command) (err error) {
+    flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+    format := addFormatFlag(flags)
+    _, err = parseFlags(flags, cmd.args)
+    if err != nil {
+        return fmt.Errorf("invalid arguments: %w", err)
+    }
+
     userlist, err := s.db.GetUsers(context.Background())
     if err != nil {return}
 
+    if customOutput(s, "users", *format) {
+        users := newListing("name", "current")
+        for _, user := range userlist {
+            users.add(user.Name, user.Name == s.cfg.User)
+        }
+        return writeListing(s, "users", *format, users)
+    }
+
     if len(userlist) == 0 {
         fmt.Println("No registered users")
         return
@@ -89,20 +122,49 @@ func handlerUsers(s *state, cmd command) (err error) {
 
 // Fetch feed by URL
 func handlerAgg(s *state, cmd command, user database.User) (err error) {
-    if len(cmd.args) < 1 {
+    flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+    tag := flags.String("tag", "", "collect only feeds with this tag")
+    args, err := parseFlags(flags, cmd.args)
+    if err != nil {
+        return fmt.Errorf("invalid arguments: %w", err)
+    }
+
+    if len(args) < 1 {
         return fmt.Errorf("%s has not enough arguments", cmd.name)
     }
 
-    requestDelay, err := time.ParseDuration(cmd.args[0])
+    requestDelay, err := time.ParseDuration(args[0])
     if err != nil {
         return fmt.Errorf("invalid duration: %w", err)
     }
 
+    // the listener receives WebSub content pushed by hubs
+    if s.cfg.ListenAddr != "" {
+        go func() {
+            log.Printf("Listening on %s", s.cfg.ListenAddr)
+            server := &http.Server{
+                Addr: s.cfg.ListenAddr,
+                Handler: newServerMux(s),
+                ReadHeaderTimeout: 10 * time.Second,
+            }
+            err := server.ListenAndServe()
+            log.Fatalf("HTTP listener stopped: %v", err)
+        }()
+    }
+
     log.Printf("Collecting feeds every %s", requestDelay)
 
     ticker := time.NewTicker(requestDelay)
     for ; ; <-ticker.C {
-        scrapeFeeds(s, user)
+        scrapeFeeds(s, user, normalizeTag(*tag))
+
+        // digests go out as soon as they are due when a mail server is set
+        if s.cfg.SMTP != nil {
+            _, err := sendDueDigests(s)
+            if err != nil {
+                log.Println(err)
+            }
+        }
     }
 }
 
@@ -126,6 +188,38 @@ func parseTime(timeStr string) (parsedTime time.Time, err error) {
     return time.Time{}, fmt.Errorf("unable to parse time: %s", timeStr)
 }
 
+// Parse a date given by a user: a calendar date, a timestamp
+// or a period back from now such as 36h or 7d
+func parseDate(dateStr string) (parsedTime time.Time, err error) {
+    if days, found := strings.CutSuffix(dateStr, "d"); found {
+        dayCount, err := strconv.Atoi(days)
+        if err == nil {
+            // periods count back from now, a negative one would be in the future
+            if dayCount < 0 {
+                return time.Time{}, fmt.Errorf("invalid period: %s, it can't be negative", dateStr)
+            }
+            return time.Now().UTC().AddDate(0, 0, -dayCount), err
+        }
+    }
+
+    period, err := time.ParseDuration(dateStr)
+    if err == nil {
+        if period < 0 {
+            return time.Time{}, fmt.Errorf("invalid period: %s, it can't be negative", dateStr)
+        }
+        return time.Now().UTC().Add(-period), err
+    }
+
+    for _, layout := range []string{time.DateOnly, time.DateTime, time.RFC3339} {
+        parsedTime, err = time.Parse(layout, dateStr)
+        if err == nil {
+            return parsedTime, err
+        }
+    }
+
+    return time.Time{}, fmt.Errorf("invalid date: %s", dateStr)
+}
+
 // Scrape a feed and print its details
 func scrapeFeed(s *state, nextFeed database.GetNextToFetchRow) {
     // mark the feed as fetched or update fetched time
@@ -136,28 +230,53 @@ func scrapeFeed(s *state, nextFeed database.GetNextToFetchRow) {
     }
 
     // fetch the feed
-    feed, err := fetchFeed(s.client, context.Background(), nextFeed.Url)
+    feed, err := fetchFeed(s.client, context.Background(), nextFeed.Url, feedRequestSettings(s.cfg, nextFeed.Url))
+    recordFeedError(s, nextFeed.ID, err)
     if err != nil {
         log.Printf("Couldn't collect feed %s: %v", nextFeed.Name, err)
         return
     }
 
     log.Printf("Feed %s collected. Found %v posts.", feed.Channel.Title, len(feed.Channel.Item))
+    ingestFeed(s, nextFeed.ID, nextFeed.ExtractContent, feed)
+
+    if hub, topic := feedHub(feed, nextFeed.Url); hub != "" {
+        err = subscribeWebSub(s, nextFeed.ID, hub, topic)
+        if err != nil {
+            log.Printf("Couldn't subscribe to hub %s for feed %s: %v", hub, nextFeed.Name, err)
+        }
+    }
+}
+
+// Save posts of a fetched or pushed feed
+func ingestFeed(s *state, feedID int32, extractContent bool, feed *RSSFeed) {
+    var newPosts []database.Post
+    defer func() {
+        err := applyIngestFilters(s, feedID, newPosts)
+        if err != nil {
+            log.Printf("Couldn't apply filter rules for feed %d: %v", feedID, err)
+        }
+    }()
+
     for _, el := range feed.Channel.Item {
         parsedTime, err := parseTime(el.PubDate)
         if err != nil {return}
 
-        _, err = s.db.CreatePost(context.Background(), database.CreatePostParams{
+        post, err := s.db.CreatePost(context.Background(), database.CreatePostParams{
             Title: el.Title,
             Url: el.Link,
             Description: sql.NullString{
-                String: el.Description,
+                String: htmltext.Sanitize(el.Description),
                 Valid: true,
             },
             PublishedAt: parsedTime,  // probably may be NULL
-            FeedID: nextFeed.ID,
+            FeedID: feedID,
             CreatedAt: time.Now().UTC(),
             UpdatedAt: time.Now().UTC(),
+            Guid: sql.NullString{
+                String: strings.TrimSpace(el.GUID),
+                Valid: strings.TrimSpace(el.GUID) != "",
+            },
         })
 
         if err != nil && err.Error() == "pq: duplicate key value violates unique constraint \"posts_url_key\"" {
@@ -167,34 +286,444 @@ func scrapeFeed(s *state, nextFeed database.GetNextToFetchRow) {
             log.Println(err)
             return
         }
+        newPosts = append(newPosts, post)
+
+        if extractContent {
+            _, err = extractPostContent(s, post)
+            if err != nil {
+                log.Printf("Couldn't extract content of %s: %v", post.Url, err)
+            }
+        }
+    }
+}
+
+// Fetch the full article of a post and save it
+func extractPostContent(s *state, post database.Post) (content string, err error) {
+    content, err = fetchArticle(s.client, context.Background(), post.Url)
+    if err != nil {return}
+
+    err = s.db.SetPostContent(context.Background(), database.SetPostContentParams{
+        ID: post.ID,
+        Content: sql.NullString{String: content, Valid: true},
+    })
+
+    return content, err
+}
+
+// Get a post of a followed feed or a starred post
+func getPostForUser(s *state, user database.User, postID int32) (post database.Post, err error) {
+    post, err = s.db.GetPostForUser(context.Background(), database.GetPostForUserParams{
+        ID: postID,
+        UserID: user.ID,
+    })
+    if errors.Is(err, sql.ErrNoRows) {
+        return post, fmt.Errorf("post %d is not in a followed feed", postID)
+    }
+    if err != nil {
+        return post, fmt.Errorf("couldn't get post: %w", err)
+    }
+    return post, err
+}
+
+// Show the full article of a post, extracting it from the post page if needed
+func handlerRead(s *state, cmd command, user database.User) (err error) {
+    if len(cmd.args) < 1 {
+        return fmt.Errorf("%s has not enough arguments", cmd.name)
+    }
+
+    postID, err := parsePostID(cmd.args[0])
+    if err != nil {return}
+
+    post, err := getPostForUser(s, user, postID)
+    if err != nil {return}
+
+    content := post.Content.String
+    if !post.Content.Valid {
+        content, err = extractPostContent(s, post)
+        if err != nil {
+            return fmt.Errorf("couldn't extract article: %w", err)
+        }
+    }
+
+    err = s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+        UserID: user.ID,
+        PostID: post.ID,
+    })
+    if err != nil {
+        return fmt.Errorf("couldn't mark post read: %w", err)
+    }
+
+    defer startPager(s)()
+    fmt.Println("Title:", post.Title, "|", "Published at:", post.PublishedAt.Format(s.options.timeFormat))
+    fmt.Println("URL:", post.Url)
+    fmt.Println(printDelimiter)
+    fmt.Println(htmltext.Render(content, terminalWidth()))
+
+    return err
+}
+
+// Open a post in the browser and mark it read
+func handlerOpen(s *state, cmd command, user database.User) (err error) {
+    if len(cmd.args) < 1 {
+        return fmt.Errorf("%s has not enough arguments", cmd.name)
+    }
+
+    postID, err := parsePostID(cmd.args[0])
+    if err != nil {return}
+
+    post, err := getPostForUser(s, user, postID)
+    if err != nil {return}
+
+    err = openBrowser(post.Url)
+    if err != nil {
+        return fmt.Errorf("couldn't open %s: %w", post.Url, err)
+    }
+
+    err = s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+        UserID: user.ID,
+        PostID: post.ID,
+    })
+    if err != nil {
+        return fmt.Errorf("couldn't mark post read: %w", err)
+    }
+
+    fmt.Println("Opened", post.Url)
+    return err
+}
+
+// Mark posts of followed feeds read by feed, by publishing date or all at once
+func handlerMarkRead(s *state, cmd command, user database.User) (err error) {
+    flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+    feedURL := flags.String("feed", "", "mark posts of the feed with this URL")
+    before := flags.String("before", "", "mark posts published before a date (2006-01-02) or a period ago (e.g. 7d)")
+    all := flags.Bool("all", false, "mark all the posts")
+    _, err = parseFlags(flags, cmd.args)
+    if err != nil {
+        return fmt.Errorf("invalid arguments: %w", err)
+    }
+
+    if *feedURL == "" && *before == "" && !*all {
+        return fmt.Errorf("%s needs --feed, --before or --all", cmd.name)
+    }
+
+    params := database.MarkPostsReadParams{
+        UserID: user.ID,
+        FeedUrl: *feedURL,
+    }
+    if *before != "" {
+        beforeTime, err := parseDate(*before)
+        if err != nil {return err}
+        params.Before = sql.NullTime{Time: beforeTime, Valid: true}
+    }
+
+    marked, err := s.db.MarkPostsRead(context.Background(), params)
+    if err != nil {
+        return fmt.Errorf("couldn't mark posts read: %w", err)
+    }
+
+    fmt.Printf("%d posts marked as read\n", marked)
+
+    return err
+}
+
+// Turn automatic article extraction on or off for a feed
+func handlerAutoExtract(s *state, cmd command, user database.User) (err error) {
+    if len(cmd.args) < 2 {
+        return fmt.Errorf("%s has not enough arguments", cmd.name)
+    }
+
+    var enabled bool
+    switch cmd.args[1] {
+    case "on":
+        enabled = true
+    case "off":
+        enabled = false
+    default:
+        return fmt.Errorf("%s expects on or off, got %q", cmd.name, cmd.args[1])
+    }
+
+    updated, err := s.db.SetFeedExtractContent(context.Background(), database.SetFeedExtractContentParams{
+        UserID: user.ID,
+        Url: cmd.args[0],
+        ExtractContent: enabled,
+    })
+    if err != nil {
+        return fmt.Errorf("couldn't update feed: %w", err)
+    }
+    if updated == 0 {
+        return fmt.Errorf("feed %s is not followed", cmd.args[0])
+    }
+
+    fmt.Println("Automatic article extraction for", cmd.args[0], "is", cmd.args[1])
+
+    return err
+}
+
+// Save the reason of a failed fetch on the feed or clear it after a successful one
+func recordFeedError(s *state, feedID int32, fetchErr error) {
+    lastError := sql.NullString{}
+    if fetchErr != nil {
+        lastError = sql.NullString{String: fetchErr.Error(), Valid: true}
+    }
+
+    err := s.db.SetFeedFetchError(context.Background(), database.SetFeedFetchErrorParams{
+        ID: feedID,
+        LastFetchError: lastError,
+    })
+    if err != nil {
+        log.Printf("Couldn't save fetch error for feed %d: %v", feedID, err)
     }
 }
 
-// Browse saved posts
+// Browse saved posts, unread ones by default
 func handlerBrowsePosts(s *state, cmd command, user database.User) (err error) {
+    flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+    unreadOnly := flags.Bool("unread", true, "show only unread posts")
+    showAll := flags.Bool("all", false, "show read posts too")
+    tag := flags.String("tag", "", "show only posts of feeds with this tag")
+    unfiltered := flags.Bool("unfiltered", false, "show posts muted by filter rules too")
+    feedName := flags.String("feed", "", "show only posts of a feed given by URL or name, or of a smart feed")
+    since := flags.String("since", "", "posts published since a date (2006-01-02) or a period ago (e.g. 7d)")
+    until := flags.String("until", "", "posts published before a date (2006-01-02) or a period ago (e.g. 7d)")
+    offset := flags.Int("offset", 0, "number of posts to skip")
+    after := flags.Int("after", 0, "show posts that come after the post with this ID")
+    sortBy := flags.String("sort", "published", "sort by published or fetched time")
+    interactive := flags.Bool("interactive", false, "show posts page by page")
+    format := addFormatFlag(flags)
+    args, err := parseFlags(flags, cmd.args)
+    if err != nil {
+        return fmt.Errorf("invalid arguments: %w", err)
+    }
+
     postLimit := 2
-    if len(cmd.args) > 0 {
-        postLimit, err = strconv.Atoi(cmd.args[0])
+    if len(args) > 0 {
+        postLimit, err = strconv.Atoi(args[0])
         if err != nil {
             log.Println(err)
             return fmt.Errorf("invalid limit: %w", err)
         }
     }
+    if postLimit <= 0 {
+        return fmt.Errorf("invalid limit: %d", postLimit)
+    }
+
+    if *interactive && customOutput(s, "posts", *format) {
+        return errors.New("interactive mode can't be used with --output, --format or a posts template")
+    }
 
-    posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
+    if *sortBy != "published" && *sortBy != "fetched" {
+        return fmt.Errorf("sort must be published or fetched, got %q", *sortBy)
+    }
+    // the next page hint continues with --after, which already skips the previous pages
+    if *offset != 0 && *after != 0 {
+        return errors.New("--offset and --after can't be used together")
+    }
+
+    params := database.GetPostsForUserParams{
         UserID: user.ID,
-        Limit: int32(postLimit),
-    })
-    if err != nil {
-        return fmt.Errorf("couldn't get posts for user: %w", err)
+        UnreadOnly: *unreadOnly && !*showAll,
+        Tag: normalizeTag(*tag),
+        Feed: *feedName,
+        After: int32(*after),
+        SortBy: *sortBy,
+    }
+    if *since != "" {
+        sinceTime, err := parseDate(*since)
+        if err != nil {return err}
+        params.Since = sql.NullTime{Time: sinceTime, Valid: true}
+    }
+    if *until != "" {
+        untilTime, err := parseDate(*until)
+        if err != nil {return err}
+        params.Until = sql.NullTime{Time: untilTime, Valid: true}
+    }
+
+    var filters postFilters
+    if !*unfiltered {
+        filters, err = loadFilters(s, user.ID)
+        if err != nil {
+            return fmt.Errorf("couldn't load filter rules: %w", err)
+        }
+    }
+
+    fetch := func(limit, offset int32) ([]database.GetPostsForUserRow, error) {
+        pageParams := params
+        pageParams.Limit, pageParams.Offset = limit, offset
+        return s.db.GetPostsForUser(context.Background(), pageParams)
+    }
+
+    // smart feeds take precedence over feeds with the same name
+    if *feedName != "" {
+        smartFeed, err := s.db.GetSmartFeed(context.Background(), database.GetSmartFeedParams{
+            UserID: user.ID,
+            Name: *feedName,
+        })
+        if err == nil {
+            fetch = smartFeedPager(s, smartFeed, params)
+        } else if !errors.Is(err, sql.ErrNoRows) {
+            return fmt.Errorf("couldn't get smart feed %q: %w", *feedName, err)
+        }
     }
 
+    if !*interactive {
+        defer startPager(s)()
+    }
+
+    input := bufio.NewReader(os.Stdin)
+    nextOffset := int32(*offset)
+    for page := 1; ; page++ {
+        var posts []database.GetPostsForUserRow
+        posts, nextOffset, err = getFilteredPosts(fetch, int32(postLimit), nextOffset, filters)
+        if err != nil {
+            return fmt.Errorf("couldn't get posts for user: %w", err)
+        }
+
+        if customOutput(s, "posts", *format) {
+            return writeListing(s, "posts", *format, postListing(posts))
+        }
+
+        if len(posts) == 0 {
+            if page == 1 {
+                fmt.Println("No posts found")
+            }
+            return err
+        }
+
+        printPosts(s, posts)
+
+        if len(posts) < postLimit {
+            return err
+        }
+        if !*interactive {
+            fmt.Printf("Next page: --after %d\n", posts[len(posts)-1].ID)
+            return err
+        }
+
+        fmt.Printf("-- page %d: press Enter for the next page or q to quit --\n", page)
+        answer, err := input.ReadString('\n')
+        if err != nil || strings.TrimSpace(strings.ToLower(answer)) == "q" {
+            return nil
+        }
+    }
+}
+
+func postListing(posts []database.GetPostsForUserRow) *listing {
+    list := newListing("id", "title", "url", "feed", "feed_id", "published_at", "fetched_at", "read_at", "description")
     for _, post := range posts {
-        fmt.Println("Title:", post.Title, "|", "Published at:", post.PublishedAt.Format(outputTimeFormat))
+        list.add(post.ID, post.Title, post.Url, post.FeedName, post.FeedID, post.PublishedAt, post.CreatedAt, post.ReadAt, post.Description)
+    }
+    return list
+}
+
+// Print posts with their rendered descriptions
+func printPosts(s *state, posts []database.GetPostsForUserRow) {
+    for _, post := range posts {
+        status := "unread"
+        if post.ReadAt.Valid {
+            status = "read"
+        }
+        fmt.Println("ID:", post.ID, "|", "Title:", post.Title, "|", "Feed:", post.FeedName)
+        fmt.Println("Published at:", post.PublishedAt.Format(s.options.timeFormat), "|", status)
+        fmt.Println("URL:", post.Url)
         if post.Description.Valid {
             fmt.Println("Description")
-            fmt.Println(post.Description.String)
+            fmt.Println(htmltext.Render(post.Description.String, terminalWidth()))
+        }
+        fmt.Println(printDelimiter)
+    }
+}
+
+// Parse a post ID given as a command argument
+func parsePostID(arg string) (postID int32, err error) {
+    id, err := strconv.ParseInt(arg, 10, 32)
+    if err != nil {
+        return postID, fmt.Errorf("invalid post ID: %w", err)
+    }
+    return int32(id), err
+}
+
+// Star a post to keep it at hand
+func handlerStar(s *state, cmd command, user database.User) (err error) {
+    if len(cmd.args) < 1 {
+        return fmt.Errorf("%s has not enough arguments", cmd.name)
+    }
+
+    postID, err := parsePostID(cmd.args[0])
+    if err != nil {return}
+
+    starred, err := s.db.StarPost(context.Background(), database.StarPostParams{
+        UserID: user.ID,
+        PostID: postID,
+    })
+    if err != nil {
+        return fmt.Errorf("couldn't star post: %w", err)
+    }
+    if starred == 0 {
+        return fmt.Errorf("post %d is not in a followed feed", postID)
+    }
+
+    fmt.Println("Post", postID, "starred")
+
+    return err
+}
+
+// Remove a star from a post
+func handlerUnstar(s *state, cmd command, user database.User) (err error) {
+    if len(cmd.args) < 1 {
+        return fmt.Errorf("%s has not enough arguments", cmd.name)
+    }
+
+    postID, err := parsePostID(cmd.args[0])
+    if err != nil {return}
+
+    unstarred, err := s.db.UnstarPost(context.Background(), database.UnstarPostParams{
+        UserID: user.ID,
+        PostID: postID,
+    })
+    if err != nil {
+        return fmt.Errorf("couldn't unstar post: %w", err)
+    }
+    if unstarred == 0 {
+        return fmt.Errorf("post %d is not starred", postID)
+    }
+
+    fmt.Println("Post", postID, "unstarred")
+
+    return err
+}
+
+// Print the starred posts of the current user
+func handlerStarred(s *state, cmd command, user database.User) (err error) {
+    flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+    format := addFormatFlag(flags)
+    _, err = parseFlags(flags, cmd.args)
+    if err != nil {
+        return fmt.Errorf("invalid arguments: %w", err)
+    }
+
+    posts, err := s.db.GetStarredPosts(context.Background(), user.ID)
+    if err != nil {
+        return fmt.Errorf("couldn't get starred posts: %w", err)
+    }
+
+    if customOutput(s, "starred", *format) {
+        starred := newListing("id", "title", "url", "feed", "published_at", "starred_at")
+        for _, post := range posts {
+            starred.add(post.ID, post.Title, post.Url, post.FeedName, post.PublishedAt, post.StarredAt)
         }
+        return writeListing(s, "starred", *format, starred)
+    }
+
+    if len(posts) == 0 {
+        fmt.Println("No starred posts")
+        return err
+    }
+
+    fmt.Printf("Found %d starred posts:\n", len(posts))
+    for _, post := range posts {
+        fmt.Println("ID:", post.ID, "|", "Title:", post.Title, "|", "Feed:", post.FeedName)
+        fmt.Println("Published at:", post.PublishedAt.Format(s.options.timeFormat), "|", "Starred at:", post.StarredAt.Time.Format(s.options.timeFormat))
+        fmt.Println("URL:", post.Url)
         fmt.Println(printDelimiter)
     }
 
@@ -202,9 +731,16 @@ func handlerBrowsePosts(s *state, cmd command, user database.User) (err error) {
 }
 
 // Feed aggregation
-func scrapeFeeds(s *state, user database.User) {
+func scrapeFeeds(s *state, user database.User, tag string) {
     // get the latest/unfetched feed
-    nextFeed, err := s.db.GetNextToFetch(context.Background(), user.ID)
+    nextFeed, err := s.db.GetNextToFetch(context.Background(), database.GetNextToFetchParams{
+        UserID: user.ID,
+        Tag: tag,
+    })
+    if errors.Is(err, sql.ErrNoRows) {
+        log.Println("No feeds to fetch")
+        return
+    }
     if err != nil {
         log.Println("Couldn't get next feeds to fetch", err)
         return
@@ -213,56 +749,136 @@ func scrapeFeeds(s *state, user database.User) {
     scrapeFeed(s, nextFeed)
 }
 
-// Add feed to DB
+// Add feed to DB. If the feed is already there, follow it under the given name instead
 func handlerAddFeed(s *state, cmd command, user database.User) (err error) {
     if len(cmd.args) < 2 {
         return fmt.Errorf("%s has not enough arguments", cmd.name)
     }
+    feedName, feedURL := cmd.args[0], cmd.args[1]
 
-    feed, err := s.db.CreateFeed(context.Background(), database.CreateFeedParams{
-        Name: cmd.args[0],
-        Url: cmd.args[1],
-        UserID: user.ID,
-        CreatedAt: time.Now().UTC(),
-        UpdatedAt: time.Now().UTC(),
-    })
+    feed, err := s.db.GetFeed(context.Background(), feedURL)
+    if errors.Is(err, sql.ErrNoRows) {
+        feed, err = s.db.CreateFeed(context.Background(), database.CreateFeedParams{
+            Name: feedName,
+            Url: feedURL,
+            UserID: user.ID,
+            CreatedAt: time.Now().UTC(),
+            UpdatedAt: time.Now().UTC(),
+        })
+    }
     if err != nil {
         return fmt.Errorf("couldn't create feed: %w", err)
     }
 
-    _, err = s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+    followedFeed, err := s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
         UserID: user.ID,
         FeedID: feed.ID,
         CreatedAt: time.Now().UTC(),
         UpdatedAt: time.Now().UTC(),
+        Alias: feedAlias(feed, feedName),
     })
     if err != nil {
         return fmt.Errorf("couldn't create feed follow: %w", err)
     }
 
-    printFeed(feed)
+    feed.Name = followedFeed.FeedName
+    printFeed(s, feed)
     fmt.Println(printDelimiter)
 
     return err
 }
 
+// Get the alias for a followed feed, there's none if the name matches the feed one
+func feedAlias(feed database.Feed, name string) sql.NullString {
+    name = strings.TrimSpace(name)
+    if name == "" || name == feed.Name {
+        return sql.NullString{}
+    }
+    return sql.NullString{String: name, Valid: true}
+}
+
+// Set or clear the name a feed is shown under for the current user
+func handlerAlias(s *state, cmd command, user database.User) (err error) {
+    if len(cmd.args) < 1 {
+        return fmt.Errorf("%s has not enough arguments", cmd.name)
+    }
+
+    feed, err := s.db.GetFeed(context.Background(), cmd.args[0])
+    if err != nil {
+        return fmt.Errorf("couldn't get feed: %w", err)
+    }
+
+    alias := sql.NullString{}
+    if len(cmd.args) > 1 {
+        alias = feedAlias(feed, strings.Join(cmd.args[1:], " "))
+    }
+
+    updated, err := s.db.SetFeedFollowAlias(context.Background(), database.SetFeedFollowAliasParams{
+        UserID: user.ID,
+        Url: feed.Url,
+        Alias: alias,
+    })
+    if err != nil {
+        return fmt.Errorf("couldn't set alias: %w", err)
+    }
+    if updated == 0 {
+        return fmt.Errorf("feed %s is not followed", feed.Url)
+    }
+
+    if alias.Valid {
+        fmt.Printf("Feed %s is now shown as %q\n", feed.Url, alias.String)
+    } else {
+        fmt.Printf("Feed %s is now shown as %q\n", feed.Url, feed.Name)
+    }
+
+    return err
+}
+
 // Print general info on a given feed
-func printFeed(feed database.Feed) {
+func printFeed(s *state, feed database.Feed) {
     fmt.Println("* ID:", feed.ID)
-	fmt.Println("* Created:", feed.CreatedAt.Format(outputTimeFormat))
-	fmt.Println("* Updated:", feed.UpdatedAt.Format(outputTimeFormat))
+	fmt.Println("* Created:", feed.CreatedAt.Format(s.options.timeFormat))
+	fmt.Println("* Updated:", feed.UpdatedAt.Format(s.options.timeFormat))
 	fmt.Println("* Name:", feed.Name)
 	fmt.Println("* URL:", feed.Url)
-    fmt.Println("* Last Fetched At:", feed.LastFetchedAt.Time.Format(outputTimeFormat))
+    fmt.Println("* Last Fetched At:", feed.LastFetchedAt.Time.Format(s.options.timeFormat))
+    if feed.LastFetchError.Valid {
+        fmt.Println("* Last Fetch Error:", feed.LastFetchError.String)
+    }
+}
+
+func feedListing(feeds []database.Feed) *listing {
+    list := newListing("id", "name", "url", "created_at", "updated_at", "last_fetched_at", "last_fetch_error")
+    for _, feed := range feeds {
+        list.add(feed.ID, feed.Name, feed.Url, feed.CreatedAt, feed.UpdatedAt, feed.LastFetchedAt, feed.LastFetchError)
+    }
+    return list
 }
 
-// Get all the feeds from DB
+// Get all the feeds from DB, named the way the current user follows them
 func handlerGetFeeds(s *state, cmd command) (err error) {
-    feeds, err := s.db.GetFeeds(context.Background())
+    flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+    format := addFormatFlag(flags)
+    _, err = parseFlags(flags, cmd.args)
+    if err != nil {
+        return fmt.Errorf("invalid arguments: %w", err)
+    }
+
+    // followed feeds are named by the aliases of the current user, if anyone is logged in
+    user, err := s.db.GetUser(context.Background(), s.cfg.User)
+    if err != nil && !errors.Is(err, sql.ErrNoRows) {
+        return fmt.Errorf("couldn't get the current user: %w", err)
+    }
+
+    feeds, err := s.db.GetFeedsForUser(context.Background(), user.ID)
     if err != nil {
         return fmt.Errorf("couldn't get feeds: %w", err)
     }
 
+    if customOutput(s, "feeds", *format) {
+        return writeListing(s, "feeds", *format, feedListing(feeds))
+    }
+
     if len(feeds) == 0 {
 		fmt.Println("No feeds found")
 		return err
@@ -270,7 +886,7 @@ func handlerGetFeeds(s *state, cmd command) (err error) {
 
     fmt.Printf("Found %d feeds:\n", len(feeds))
     for _, feed := range feeds {
-        printFeed(feed)
+        printFeed(s, feed)
         fmt.Println(printDelimiter)
     }
 
@@ -286,11 +902,17 @@ func handlerFollow(s *state, cmd command, user database.User) (err error) {
     feed, err := s.db.GetFeed(context.Background(), cmd.args[0])
     if err != nil {return}
 
+    alias := sql.NullString{}
+    if len(cmd.args) > 1 {
+        alias = feedAlias(feed, strings.Join(cmd.args[1:], " "))
+    }
+
     followedFeed, err := s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
         UserID: user.ID,
         FeedID: feed.ID,
         CreatedAt: time.Now().UTC(),
         UpdatedAt: time.Now().UTC(),
+        Alias: alias,
     })
 
     fmt.Println("You are now following:")
@@ -311,21 +933,249 @@ func handlerUnfollow(s *state, cmd command, user database.User) (err error) {
     })
 }
 
-// Print all the names of the feeds the current user is following
+// Print all the names of the feeds the current user is following grouped by tag
 func handlerFollowing(s *state, cmd command, user database.User) (err error) {
+    flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+    format := addFormatFlag(flags)
+    _, err = parseFlags(flags, cmd.args)
+    if err != nil {
+        return fmt.Errorf("invalid arguments: %w", err)
+    }
+
     followedFeeds, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
     if err != nil {return}
 
+    if customOutput(s, "follows", *format) {
+        smartFeeds, err := s.db.GetSmartFeedsForUser(context.Background(), user.ID)
+        if err != nil {return err}
+
+        // smart feeds have a search query instead of a URL and tags,
+        // rows are repeated for every tag of a feed
+        follows := newListing("kind", "feed_id", "name", "url", "tags", "query")
+        tags := map[int32][]string{}
+        var feeds []database.GetFeedFollowsForUserRow
+        for _, feed := range followedFeeds {
+            if _, seen := tags[feed.FeedID]; !seen {
+                tags[feed.FeedID] = []string{}
+                feeds = append(feeds, feed)
+            }
+            if feed.Tag.Valid {
+                tags[feed.FeedID] = append(tags[feed.FeedID], feed.Tag.String)
+            }
+        }
+        for _, feed := range feeds {
+            follows.add("feed", feed.FeedID, feed.FeedName, feed.FeedUrl, tags[feed.FeedID], nil)
+        }
+        for _, smartFeed := range smartFeeds {
+            follows.add("smart", smartFeed.Name, nil, nil, smartFeed.Query)
+        }
+        return writeListing(s, "follows", *format, follows)
+    }
+
     if len(followedFeeds) == 0 {
         fmt.Println("No following feeds.")
         return err
     }
 
+    // rows come ordered by tag, a feed is listed under each of its tags
     fmt.Println("Your followed feeds:")
-    for _, feed := range followedFeeds {
+    currentGroup := ""
+    for idx, feed := range followedFeeds {
+        group := feed.Tag.String
+        if !feed.Tag.Valid {
+            group = "untagged"
+        }
+        if idx == 0 || group != currentGroup {
+            currentGroup = group
+            fmt.Printf("[%s]\n", group)
+        }
         fmt.Println("*", feed.FeedName)
     }
 
+    smartFeeds, err := s.db.GetSmartFeedsForUser(context.Background(), user.ID)
+    if err != nil {return}
+
+    if len(smartFeeds) > 0 {
+        fmt.Println("[smart feeds]")
+    }
+    for _, smartFeed := range smartFeeds {
+        fmt.Printf("* %s (search: %s)\n", smartFeed.Name, smartFeed.Query)
+    }
+
+    return err
+}
+
+// Tags are case insensitive
+func normalizeTag(tag string) string {
+    return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// Add a tag to a followed feed to group it with others
+func handlerTag(s *state, cmd command, user database.User) (err error) {
+    if len(cmd.args) < 2 {
+        return fmt.Errorf("%s has not enough arguments", cmd.name)
+    }
+
+    tag := normalizeTag(cmd.args[1])
+    if tag == "" {
+        return errors.New("tag can't be empty")
+    }
+
+    tagged, err := s.db.TagFeedFollow(context.Background(), database.TagFeedFollowParams{
+        Tag: tag,
+        UserID: user.ID,
+        Url: cmd.args[0],
+    })
+    if err != nil {
+        return fmt.Errorf("couldn't tag feed: %w", err)
+    }
+    if tagged == 0 {
+        return fmt.Errorf("feed %s is not followed or already tagged %q", cmd.args[0], tag)
+    }
+
+    fmt.Printf("Feed %s tagged %q\n", cmd.args[0], tag)
+
+    return err
+}
+
+// Remove a tag from a followed feed
+func handlerUntag(s *state, cmd command, user database.User) (err error) {
+    if len(cmd.args) < 2 {
+        return fmt.Errorf("%s has not enough arguments", cmd.name)
+    }
+
+    tag := normalizeTag(cmd.args[1])
+    untagged, err := s.db.UntagFeedFollow(context.Background(), database.UntagFeedFollowParams{
+        UserID: user.ID,
+        Url: cmd.args[0],
+        Tag: tag,
+    })
+    if err != nil {
+        return fmt.Errorf("couldn't untag feed: %w", err)
+    }
+    if untagged == 0 {
+        return fmt.Errorf("feed %s is not tagged %q", cmd.args[0], tag)
+    }
+
+    fmt.Printf("Tag %q removed from feed %s\n", tag, cmd.args[0])
+
+    return err
+}
+
+// Repeatable flag for HTTP headers in the "Name: value" form
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+    return fmt.Sprint(map[string]string(h))
+}
+
+func (h headerFlags) Set(value string) error {
+    name, headerValue, found := strings.Cut(value, ":")
+    if !found || strings.TrimSpace(name) == "" {
+        return fmt.Errorf("header must look like \"Name: value\", got %q", value)
+    }
+    h[strings.TrimSpace(name)] = strings.TrimSpace(headerValue)
+    return nil
+}
+
+// Set headers, basic auth credentials, a query token or TLS overrides used to fetch a feed.
+// The settings are saved to the local config only and never printed back.
+func handlerFeedAuth(s *state, cmd command, user database.User) (err error) {
+    headers := headerFlags{}
+    flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+    flags.Var(headers, "header", "HTTP header in the \"Name: value\" form, can be repeated")
+    basic := flags.String("basic", "", "basic auth credentials in the user:password form")
+    token := flags.String("token", "", "query token in the param=value form")
+    caFile := flags.String("ca", "", "PEM bundle with extra CAs trusted for the feed")
+    certFile := flags.String("cert", "", "client certificate file")
+    keyFile := flags.String("key", "", "client key file")
+    insecure := flags.Bool("insecure", false, "skip TLS certificate verification")
+    clearSettings := flags.Bool("clear", false, "remove all the request settings of the feed")
+    args, err := parseFlags(flags, cmd.args)
+    if err != nil {
+        return fmt.Errorf("invalid arguments: %w", err)
+    }
+    if len(args) < 1 {
+        return fmt.Errorf("%s has not enough arguments", cmd.name)
+    }
+    feedURL := args[0]
+
+    follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+    if err != nil {
+        return fmt.Errorf("couldn't get feed follows: %w", err)
+    }
+    followed := slices.ContainsFunc(follows, func(follow database.GetFeedFollowsForUserRow) bool {
+        return follow.FeedUrl == feedURL
+    })
+    if !followed {
+        return fmt.Errorf("feed %s is not followed", feedURL)
+    }
+
+    if *clearSettings {
+        err = s.cfg.SetFeedRequest(feedURL, config.FeedRequest{})
+        if err != nil {
+            return fmt.Errorf("couldn't save config: %w", err)
+        }
+        fmt.Println("Request settings removed for", feedURL)
+        return err
+    }
+
+    // settings that aren't given are kept
+    settings := s.cfg.FeedRequests[feedURL]
+    for name, value := range settings.Headers {
+        if _, given := headers[name]; !given {
+            headers[name] = value
+        }
+    }
+    settings.Headers = headers
+    flags.Visit(func(f *flag.Flag) {
+        switch f.Name {
+        case "ca":
+            settings.CAFile = *caFile
+        case "cert":
+            settings.ClientCertFile = *certFile
+        case "key":
+            settings.ClientKeyFile = *keyFile
+        case "insecure":
+            settings.InsecureSkipVerify = *insecure
+        }
+    })
+    if *basic != "" {
+        settings.BasicUser, settings.BasicPassword, _ = strings.Cut(*basic, ":")
+    }
+    if *token != "" {
+        param, value, found := strings.Cut(*token, "=")
+        if !found || param == "" {
+            return fmt.Errorf("token must look like param=value, got %q", *token)
+        }
+        settings.TokenParam, settings.Token = param, value
+    }
+
+    err = s.cfg.SetFeedRequest(feedURL, settings)
+    if err != nil {
+        return fmt.Errorf("couldn't save config: %w", err)
+    }
+
+    fmt.Println("Request settings saved for", feedURL)
+    for name := range settings.Headers {
+        fmt.Println("* Header:", name)
+    }
+    if settings.BasicUser != "" {
+        fmt.Println("* Basic auth user:", settings.BasicUser)
+    }
+    if settings.TokenParam != "" {
+        fmt.Println("* Query token parameter:", settings.TokenParam)
+    }
+    if settings.CAFile != "" {
+        fmt.Println("* CA bundle:", settings.CAFile)
+    }
+    if settings.ClientCertFile != "" {
+        fmt.Println("* Client certificate:", settings.ClientCertFile)
+    }
+    if settings.InsecureSkipVerify {
+        fmt.Println("* TLS verification disabled")
+    }
+
     return err
 }
 
@@ -13,6 +13,63 @@ const cfgFilename string = ".gatorconfig.json"
 type Config struct {
     DbUrl string `json:"db_url"`
     User string `json:"current_user_name"`
+    // hosts, IPs or CIDR ranges that feeds may be fetched from even if they are internal
+    FetchAllowlist []string `json:"fetch_allowlist,omitempty"`
+    // outbound proxy, HTTPS_PROXY/HTTP_PROXY are used if empty. NO_PROXY is honored either way
+    ProxyURL string `json:"proxy_url,omitempty"`
+    // PEM bundle with extra trusted CAs
+    CAFile string `json:"ca_file,omitempty"`
+    ClientCertFile string `json:"client_cert_file,omitempty"`
+    ClientKeyFile string `json:"client_key_file,omitempty"`
+    // address of the HTTP listener started by agg, e.g. ":8081"
+    ListenAddr string `json:"listen_addr,omitempty"`
+    // public base URL of the listener used in WebSub callbacks, e.g. "https://gator.example.com"
+    PublicURL string `json:"public_url,omitempty"`
+    // users whose rivers the listener serves at /publish/<user name>, nobody's by default
+    PublishUsers []string `json:"publish_users,omitempty"`
+    // Go time layout of dates shown in the output
+    TimeFormat string `json:"time_format,omitempty"`
+    // text/template files by listing: users, feeds, follows, posts, starred, search, filters
+    Templates map[string]string `json:"templates,omitempty"`
+    // request settings by feed URL, kept locally so that secrets never reach the database
+    FeedRequests map[string]FeedRequest `json:"feed_requests,omitempty"`
+    // mail server for digests
+    SMTP *SMTP `json:"smtp,omitempty"`
+    // bearer token required by the API served by the serve command
+    APIToken string `json:"api_token,omitempty"`
+}
+
+// Outgoing mail server settings
+type SMTP struct {
+    Host string `json:"host"`
+    // 587 by default, 465 with implicit TLS
+    Port int `json:"port,omitempty"`
+    Username string `json:"username,omitempty"`
+    Password string `json:"password,omitempty"`
+    // sender address, e.g. "Gator <gator@example.com>"
+    From string `json:"from"`
+    ImplicitTLS bool `json:"implicit_tls,omitempty"`
+}
+
+// Extra request settings for feeds that need authentication
+type FeedRequest struct {
+    Headers map[string]string `json:"headers,omitempty"`
+    BasicUser string `json:"basic_user,omitempty"`
+    BasicPassword string `json:"basic_password,omitempty"`
+    // query parameter name and value for feeds authenticated by a token in the URL
+    TokenParam string `json:"token_param,omitempty"`
+    Token string `json:"token,omitempty"`
+    // TLS overrides
+    CAFile string `json:"ca_file,omitempty"`
+    ClientCertFile string `json:"client_cert_file,omitempty"`
+    ClientKeyFile string `json:"client_key_file,omitempty"`
+    InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// check if there's nothing to apply to requests
+func (fr FeedRequest) isEmpty() bool {
+    return len(fr.Headers) == 0 && fr.BasicUser == "" && fr.TokenParam == "" &&
+        fr.CAFile == "" && fr.ClientCertFile == "" && !fr.InsecureSkipVerify
 }
 
 
@@ -22,6 +79,21 @@ func (c *Config) SetUser(username string) (err error) {
     return write(*c)
 }
 
+// save request settings for a feed or remove them if the settings are empty
+func (c *Config) SetFeedRequest(feedURL string, settings FeedRequest) (err error) {
+    if c.FeedRequests == nil {
+        c.FeedRequests = map[string]FeedRequest{}
+    }
+
+    if settings.isEmpty() {
+        delete(c.FeedRequests, feedURL)
+    } else {
+        c.FeedRequests[feedURL] = settings
+    }
+
+    return write(*c)
+}
+
 // get a full path to the config file
 func getConfigPath() (path string, err error) {
     path, err = os.UserHomeDir()
@@ -52,6 +124,19 @@ func write(cfg Config) (err error) {
     fpath , err := getConfigPath()
     if err != nil {return}
 
-    err = os.WriteFile(fpath, data, 0666)
-    return err
+    // the config may contain feed credentials, it's written to a private temporary
+    // file and moved over the old one so that existing configs get tightened too
+    file, err := os.CreateTemp(filepath.Dir(fpath), ".gatorconfig-*.json")
+    if err != nil {return}
+    defer os.Remove(file.Name())
+
+    _, err = file.Write(data)
+    if err != nil {
+        file.Close()
+        return err
+    }
+    err = file.Close()
+    if err != nil {return}
+
+    return os.Rename(file.Name(), fpath)
 }
\ No newline at end of file
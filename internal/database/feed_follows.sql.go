@@ -15,10 +15,10 @@ import (
 
 const createFeedFollow = `-- name: CreateFeedFollow :one
 WITH inserted_feed_follow AS (
-    INSERT INTO feed_follows(user_id, feed_id, created_at, updated_at)
-    VALUES ($1, $2, $3, $4)
-    RETURNING id, user_id, feed_id, created_at, updated_at
-) SELECT inserted_feed_follow.id, inserted_feed_follow.user_id, inserted_feed_follow.feed_id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, feeds.name AS feed_name, users.name AS user_name
+    INSERT INTO feed_follows(user_id, feed_id, created_at, updated_at, alias)
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING id, user_id, feed_id, created_at, updated_at, alias
+) SELECT inserted_feed_follow.id, inserted_feed_follow.user_id, inserted_feed_follow.feed_id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.alias, COALESCE(inserted_feed_follow.alias, feeds.name)::text AS feed_name, users.name AS user_name
 FROM inserted_feed_follow
 JOIN feeds
 ON inserted_feed_follow.feed_id = feeds.id
@@ -31,6 +31,7 @@ type CreateFeedFollowParams struct {
 	FeedID    int32
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	Alias     sql.NullString
 }
 
 type CreateFeedFollowRow struct {
@@ -39,6 +40,7 @@ type CreateFeedFollowRow struct {
 	FeedID    int32
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	Alias     sql.NullString
 	FeedName  string
 	UserName  string
 }
@@ -49,6 +51,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		arg.FeedID,
 		arg.CreatedAt,
 		arg.UpdatedAt,
+		arg.Alias,
 	)
 	var i CreateFeedFollowRow
 	err := row.Scan(
@@ -57,6 +60,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.FeedID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Alias,
 		&i.FeedName,
 		&i.UserName,
 	)
@@ -83,19 +87,26 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 }
 
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
-SELECT feed_follows.id, feeds.name AS feed_name, users.name AS user_name
+SELECT feed_follows.id, feed_follows.feed_id, COALESCE(feed_follows.alias, feeds.name)::text AS feed_name,
+    users.name AS user_name, feeds.url AS feed_url, feed_follow_tags.tag
 FROM feed_follows
 JOIN feeds
 ON feed_follows.feed_id = feeds.id
 JOIN users
 ON feed_follows.user_id = users.id
+LEFT JOIN feed_follow_tags
+ON feed_follows.id = feed_follow_tags.feed_follow_id
 WHERE feed_follows.user_id = $1
+ORDER BY feed_follow_tags.tag NULLS LAST, feed_name
 `
 
 type GetFeedFollowsForUserRow struct {
 	ID       int32
+	FeedID   int32
 	FeedName string
 	UserName string
+	FeedUrl  string
+	Tag      sql.NullString
 }
 
 func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
@@ -107,7 +118,14 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	var items []GetFeedFollowsForUserRow
 	for rows.Next() {
 		var i GetFeedFollowsForUserRow
-		if err := rows.Scan(&i.ID, &i.FeedName, &i.UserName); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.FeedID,
+			&i.FeedName,
+			&i.UserName,
+			&i.FeedUrl,
+			&i.Tag,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -122,26 +140,41 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 }
 
 const getNextToFetch = `-- name: GetNextToFetch :one
-SELECT f.id, name, url, f.created_at, f.updated_at, last_fetched_at
+SELECT f.id, name, url, f.created_at, f.updated_at, last_fetched_at, extract_content
 FROM feeds f
 JOIN feed_follows ff
 ON f.id = ff.feed_id
-WHERE ff.user_id = $1
+WHERE ff.user_id = $1 AND NOT EXISTS (
+    -- feeds pushed by a hub are only polled shortly before the subscription expires
+    SELECT 1
+    FROM websub_subscriptions ws
+    WHERE ws.feed_id = f.id AND ws.expires_at > NOW() + INTERVAL '1 day'
+) AND ($2::text = '' OR EXISTS (
+    SELECT 1
+    FROM feed_follow_tags fft
+    WHERE fft.feed_follow_id = ff.id AND fft.tag = $2::text
+))
 ORDER BY last_fetched_at NULLS FIRST
 LIMIT 1
 `
 
 type GetNextToFetchRow struct {
-	ID            int32
-	Name          string
-	Url           string
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	LastFetchedAt sql.NullTime
+	ID             int32
+	Name           string
+	Url            string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	LastFetchedAt  sql.NullTime
+	ExtractContent bool
 }
 
-func (q *Queries) GetNextToFetch(ctx context.Context, userID uuid.UUID) (GetNextToFetchRow, error) {
-	row := q.db.QueryRowContext(ctx, getNextToFetch, userID)
+type GetNextToFetchParams struct {
+	UserID uuid.UUID
+	Tag    string
+}
+
+func (q *Queries) GetNextToFetch(ctx context.Context, arg GetNextToFetchParams) (GetNextToFetchRow, error) {
+	row := q.db.QueryRowContext(ctx, getNextToFetch, arg.UserID, arg.Tag)
 	var i GetNextToFetchRow
 	err := row.Scan(
 		&i.ID,
@@ -150,6 +183,75 @@ func (q *Queries) GetNextToFetch(ctx context.Context, userID uuid.UUID) (GetNext
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastFetchedAt,
+		&i.ExtractContent,
 	)
 	return i, err
 }
+
+const setFeedFollowAlias = `-- name: SetFeedFollowAlias :execrows
+UPDATE feed_follows
+SET alias = $3, updated_at = NOW()
+FROM feeds
+WHERE feed_follows.feed_id = feeds.id AND feed_follows.user_id = $1 AND feeds.url = $2
+`
+
+type SetFeedFollowAliasParams struct {
+	UserID uuid.UUID
+	Url    string
+	Alias  sql.NullString
+}
+
+func (q *Queries) SetFeedFollowAlias(ctx context.Context, arg SetFeedFollowAliasParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedFollowAlias, arg.UserID, arg.Url, arg.Alias)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const tagFeedFollow = `-- name: TagFeedFollow :execrows
+INSERT INTO feed_follow_tags(feed_follow_id, tag, created_at)
+SELECT feed_follows.id, $1::text, NOW()
+FROM feed_follows
+JOIN feeds
+ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $2 AND feeds.url = $3
+ON CONFLICT DO NOTHING
+`
+
+type TagFeedFollowParams struct {
+	Tag    string
+	UserID uuid.UUID
+	Url    string
+}
+
+func (q *Queries) TagFeedFollow(ctx context.Context, arg TagFeedFollowParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, tagFeedFollow, arg.Tag, arg.UserID, arg.Url)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const untagFeedFollow = `-- name: UntagFeedFollow :execrows
+DELETE FROM feed_follow_tags
+USING feed_follows, feeds
+WHERE feed_follow_tags.feed_follow_id = feed_follows.id
+    AND feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id = $1 AND feeds.url = $2
+    AND feed_follow_tags.tag = $3::text
+`
+
+type UntagFeedFollowParams struct {
+	UserID uuid.UUID
+	Url    string
+	Tag    string
+}
+
+func (q *Queries) UntagFeedFollow(ctx context.Context, arg UntagFeedFollowParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, untagFeedFollow, arg.UserID, arg.Url, arg.Tag)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,7 +16,7 @@ import (
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds (name, url, user_id, created_at, updated_at)
 VALUES ($1, $2, $3, $4, $5)
-RETURNING id, name, url, user_id, created_at, updated_at, last_fetched_at
+RETURNING id, name, url, user_id, created_at, updated_at, last_fetched_at, last_fetch_error, extract_content
 `
 
 type CreateFeedParams struct {
@@ -43,12 +44,14 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastFetchedAt,
+		&i.LastFetchError,
+		&i.ExtractContent,
 	)
 	return i, err
 }
 
 const getFeed = `-- name: GetFeed :one
-SELECT id, name, url, user_id, created_at, updated_at, last_fetched_at
+SELECT id, name, url, user_id, created_at, updated_at, last_fetched_at, last_fetch_error, extract_content
 FROM feeds
 WHERE url = $1
 `
@@ -64,12 +67,14 @@ func (q *Queries) GetFeed(ctx context.Context, url string) (Feed, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastFetchedAt,
+		&i.LastFetchError,
+		&i.ExtractContent,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, name, url, user_id, created_at, updated_at, last_fetched_at
+SELECT id, name, url, user_id, created_at, updated_at, last_fetched_at, last_fetch_error, extract_content
 FROM feeds
 `
 
@@ -90,6 +95,8 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.LastFetchedAt,
+			&i.LastFetchError,
+			&i.ExtractContent,
 		); err != nil {
 			return nil, err
 		}
@@ -104,6 +111,99 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 	return items, nil
 }
 
+const getFeedByID = `-- name: GetFeedByID :one
+SELECT id, name, url, user_id, created_at, updated_at, last_fetched_at, last_fetch_error, extract_content
+FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) GetFeedByID(ctx context.Context, id int32) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByID, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastFetchedAt,
+		&i.LastFetchError,
+		&i.ExtractContent,
+	)
+	return i, err
+}
+
+const getFeedsForUser = `-- name: GetFeedsForUser :many
+SELECT feeds.id, COALESCE(feed_follows.alias, feeds.name)::text AS name, feeds.url, feeds.user_id,
+    feeds.created_at, feeds.updated_at, feeds.last_fetched_at, feeds.last_fetch_error, feeds.extract_content
+FROM feeds
+LEFT JOIN feed_follows ON feed_follows.feed_id = feeds.id AND feed_follows.user_id = $1
+`
+
+func (q *Queries) GetFeedsForUser(ctx context.Context, userID uuid.UUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LastFetchedAt,
+			&i.LastFetchError,
+			&i.ExtractContent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedByIDForUser = `-- name: GetFeedByIDForUser :one
+SELECT feeds.id, COALESCE(feed_follows.alias, feeds.name)::text AS name, feeds.url, feeds.user_id,
+    feeds.created_at, feeds.updated_at, feeds.last_fetched_at, feeds.last_fetch_error, feeds.extract_content
+FROM feeds
+LEFT JOIN feed_follows ON feed_follows.feed_id = feeds.id AND feed_follows.user_id = $2
+WHERE feeds.id = $1
+`
+
+type GetFeedByIDForUserParams struct {
+	ID     int32
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetFeedByIDForUser(ctx context.Context, arg GetFeedByIDForUserParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByIDForUser, arg.ID, arg.UserID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastFetchedAt,
+		&i.LastFetchError,
+		&i.ExtractContent,
+	)
+	return i, err
+}
+
 const markFeedFetched = `-- name: MarkFeedFetched :exec
 UPDATE feeds
 SET updated_at = NOW(), last_fetched_at = NOW()
@@ -114,3 +214,40 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id int32) error {
 	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
 	return err
 }
+
+const setFeedExtractContent = `-- name: SetFeedExtractContent :execrows
+UPDATE feeds
+SET extract_content = $3, updated_at = NOW()
+FROM feed_follows
+WHERE feeds.url = $2 AND feed_follows.feed_id = feeds.id AND feed_follows.user_id = $1
+`
+
+type SetFeedExtractContentParams struct {
+	UserID         uuid.UUID
+	Url            string
+	ExtractContent bool
+}
+
+func (q *Queries) SetFeedExtractContent(ctx context.Context, arg SetFeedExtractContentParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedExtractContent, arg.UserID, arg.Url, arg.ExtractContent)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setFeedFetchError = `-- name: SetFeedFetchError :exec
+UPDATE feeds
+SET last_fetch_error = $2
+WHERE id = $1
+`
+
+type SetFeedFetchErrorParams struct {
+	ID             int32
+	LastFetchError sql.NullString
+}
+
+func (q *Queries) SetFeedFetchError(ctx context.Context, arg SetFeedFetchErrorParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedFetchError, arg.ID, arg.LastFetchError)
+	return err
+}
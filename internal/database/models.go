@@ -11,14 +11,26 @@ import (
 	"github.com/google/uuid"
 )
 
+type DigestSchedule struct {
+	UserID         uuid.UUID
+	Email          string
+	Frequency      string
+	LastSentAt     sql.NullTime
+	LastSentPostID int32
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
 type Feed struct {
-	ID            int32
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	LastFetchedAt sql.NullTime
+	ID             int32
+	Name           string
+	Url            string
+	UserID         uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	LastFetchedAt  sql.NullTime
+	LastFetchError sql.NullString
+	ExtractContent bool
 }
 
 type FeedFollow struct {
@@ -27,17 +39,49 @@ type FeedFollow struct {
 	FeedID    int32
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	Alias     sql.NullString
+}
+
+type FeedFollowTag struct {
+	FeedFollowID int32
+	Tag          string
+	CreatedAt    time.Time
+}
+
+type FilterRule struct {
+	ID        int32
+	UserID    uuid.UUID
+	FeedID    sql.NullInt32
+	Action    string
+	Field     string
+	Pattern   string
+	IsRegex   bool
+	MarkRead  bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 type Post struct {
-	ID          int32
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      int32
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID           int32
+	Title        string
+	Url          string
+	Description  sql.NullString
+	PublishedAt  time.Time
+	FeedID       int32
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Content      sql.NullString
+	SearchVector interface{}
+	Guid         sql.NullString
+}
+
+type SmartFeed struct {
+	ID        int32
+	UserID    uuid.UUID
+	Name      string
+	Query     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 type User struct {
@@ -46,3 +90,26 @@ type User struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+type UserPost struct {
+	UserID    uuid.UUID
+	PostID    int32
+	ReadAt    sql.NullTime
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	StarredAt sql.NullTime
+}
+
+type WebsubSubscription struct {
+	ID            int32
+	FeedID        int32
+	HubUrl        string
+	TopicUrl      string
+	Secret        string
+	VerifiedAt    sql.NullTime
+	ExpiresAt     sql.NullTime
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	CallbackToken string
+	PendingMode   sql.NullString
+}
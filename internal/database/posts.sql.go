@@ -16,13 +16,13 @@ import (
 const createPost = `-- name: CreatePost :one
 INSERT INTO posts(
     title, url, description, published_at,
-    feed_id, created_at, updated_at
+    feed_id, created_at, updated_at, guid
 )
 VALUES (
     $1, $2, $3, $4,
-    $5, $6, $7
+    $5, $6, $7, $8
 )
-RETURNING id, title, url, description, published_at, feed_id, created_at, updated_at
+RETURNING id, title, url, description, published_at, feed_id, created_at, updated_at, content, search_vector, guid
 `
 
 type CreatePostParams struct {
@@ -33,6 +33,7 @@ type CreatePostParams struct {
 	FeedID      int32
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	Guid        sql.NullString
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -44,6 +45,7 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.FeedID,
 		arg.CreatedAt,
 		arg.UpdatedAt,
+		arg.Guid,
 	)
 	var i Post
 	err := row.Scan(
@@ -55,35 +57,164 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.FeedID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Content,
+		&i.SearchVector,
+		&i.Guid,
+	)
+	return i, err
+}
+
+const getPost = `-- name: GetPost :one
+SELECT id, title, url, description, published_at, feed_id, created_at, updated_at, content, search_vector, guid
+FROM posts
+WHERE id = $1
+`
+
+func (q *Queries) GetPost(ctx context.Context, id int32) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Content,
+		&i.SearchVector,
+		&i.Guid,
+	)
+	return i, err
+}
+
+const getPostForUser = `-- name: GetPostForUser :one
+SELECT posts.id, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.created_at, posts.updated_at, posts.content, posts.search_vector, posts.guid
+FROM posts
+WHERE posts.id = $1 AND (
+    EXISTS (
+        SELECT 1
+        FROM feed_follows
+        WHERE feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = $2
+    ) OR EXISTS (
+        SELECT 1
+        FROM user_posts
+        WHERE user_posts.post_id = posts.id AND user_posts.user_id = $2 AND user_posts.starred_at IS NOT NULL
+    )
+)
+`
+
+type GetPostForUserParams struct {
+	ID     int32
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetPostForUser(ctx context.Context, arg GetPostForUserParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostForUser, arg.ID, arg.UserID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Content,
+		&i.SearchVector,
+		&i.Guid,
 	)
 	return i, err
 }
 
 const getPostsForUser = `-- name: GetPostsForUser :many
 SELECT posts.id, title, url, description, published_at,
-    posts.feed_id, posts.created_at, posts.updated_at
+    posts.feed_id, posts.created_at, posts.updated_at, content, user_posts.read_at,
+    COALESCE(feed_follows.alias, feeds.name)::text AS feed_name, posts.guid
 FROM posts
 JOIN feed_follows
 ON posts.feed_id = feed_follows.feed_id
-WHERE user_id = $1
-ORDER BY published_at DESC
-LIMIT $2
+JOIN feeds
+ON posts.feed_id = feeds.id
+LEFT JOIN user_posts
+ON posts.id = user_posts.post_id AND user_posts.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+    AND (NOT $2::boolean OR user_posts.read_at IS NULL)
+    AND (NOT $3::boolean OR user_posts.starred_at IS NOT NULL)
+    AND ($4::text = '' OR EXISTS (
+        SELECT 1
+        FROM feed_follow_tags
+        WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND feed_follow_tags.tag = $4::text
+    ))
+    -- a feed is given by its URL or by the name the user sees
+    AND ($5::text = '' OR feeds.url = $5::text OR COALESCE(feed_follows.alias, feeds.name) = $5::text)
+    AND ($6::timestamp IS NULL OR posts.published_at >= $6::timestamp)
+    AND ($7::timestamp IS NULL OR posts.published_at < $7::timestamp)
+    -- cursor: only posts that come after the given one in the sort order
+    AND ($8::integer = 0 OR (
+        CASE WHEN $9::text = 'fetched' THEN posts.created_at ELSE posts.published_at END, posts.id
+    ) < (
+        SELECT CASE WHEN $9::text = 'fetched' THEN after_post.created_at ELSE after_post.published_at END, after_post.id
+        FROM posts after_post
+        WHERE after_post.id = $8::integer
+    ))
+ORDER BY CASE WHEN $9::text = 'fetched' THEN posts.created_at ELSE posts.published_at END DESC, posts.id DESC
+LIMIT $10
+OFFSET $11
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID      uuid.UUID
+	UnreadOnly  bool
+	StarredOnly bool
+	Tag         string
+	Feed        string
+	Since       sql.NullTime
+	Until       sql.NullTime
+	After       int32
+	SortBy      string
+	Limit       int32
+	Offset      int32
+}
+
+type GetPostsForUserRow struct {
+	ID          int32
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedID      int32
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Content     sql.NullString
+	ReadAt      sql.NullTime
+	FeedName    string
+	Guid        sql.NullString
 }
 
-func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.StarredOnly,
+		arg.Tag,
+		arg.Feed,
+		arg.Since,
+		arg.Until,
+		arg.After,
+		arg.SortBy,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostsForUserRow
 	for rows.Next() {
-		var i Post
+		var i GetPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Title,
@@ -93,6 +224,102 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.FeedID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Content,
+			&i.ReadAt,
+			&i.FeedName,
+			&i.Guid,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT posts.id, posts.title, posts.url, posts.published_at,
+    COALESCE(feed_follows.alias, feeds.name)::text AS feed_name,
+    ts_rank(posts.search_vector, search_query)::real AS rank,
+    ts_headline(
+        'english',
+        regexp_replace(
+            COALESCE(posts.description, '') || ' ' || COALESCE(posts.content, ''),
+            '<[^>]*>', ' ', 'g'
+        ),
+        search_query,
+        'StartSel=<<, StopSel=>>, MaxWords=30, MinWords=10, MaxFragments=2'
+    )::text AS snippet
+FROM posts
+JOIN feed_follows
+ON posts.feed_id = feed_follows.feed_id
+JOIN feeds
+ON posts.feed_id = feeds.id
+CROSS JOIN websearch_to_tsquery('english', $1::text) search_query
+WHERE feed_follows.user_id = $2
+    AND posts.search_vector @@ search_query
+    AND ($3::text = '' OR feeds.url = $3::text)
+    AND ($4::text = '' OR EXISTS (
+        SELECT 1
+        FROM feed_follow_tags
+        WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND feed_follow_tags.tag = $4::text
+    ))
+    AND ($5::timestamp IS NULL OR posts.published_at >= $5::timestamp)
+    AND ($6::timestamp IS NULL OR posts.published_at < $6::timestamp)
+ORDER BY rank DESC, posts.published_at DESC
+LIMIT $7
+`
+
+type SearchPostsForUserParams struct {
+	Query   string
+	UserID  uuid.UUID
+	FeedUrl string
+	Tag     string
+	Since   sql.NullTime
+	Until   sql.NullTime
+	Limit   int32
+}
+
+type SearchPostsForUserRow struct {
+	ID          int32
+	Title       string
+	Url         string
+	PublishedAt time.Time
+	FeedName    string
+	Rank        float32
+	Snippet     string
+}
+
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser,
+		arg.Query,
+		arg.UserID,
+		arg.FeedUrl,
+		arg.Tag,
+		arg.Since,
+		arg.Until,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsForUserRow
+	for rows.Next() {
+		var i SearchPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -106,3 +333,19 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const setPostContent = `-- name: SetPostContent :exec
+UPDATE posts
+SET content = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetPostContentParams struct {
+	ID      int32
+	Content sql.NullString
+}
+
+func (q *Queries) SetPostContent(ctx context.Context, arg SetPostContentParams) error {
+	_, err := q.db.ExecContext(ctx, setPostContent, arg.ID, arg.Content)
+	return err
+}
@@ -0,0 +1,197 @@
+package htmltext
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// A minimal HTML tree. Text nodes have an empty Tag.
+type Node struct {
+	Tag string
+	Attrs []xml.Attr
+	Text string
+	Children []*Node
+	Parent *Node
+}
+
+// Elements whose content is never shown to a reader
+var rawTextTags = map[string]bool{"script": true, "style": true}
+
+// Context of fragments, whole documents parse the same way without their html, head and body tags
+var fragmentContext = &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+
+
+// Get an attribute value by its name
+func (n *Node) Attr(name string) string {
+	for _, attr := range n.Attrs {
+		if strings.EqualFold(attr.Name.Local, name) {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func (n *Node) appendChild(child *Node) {
+	child.Parent = n
+	n.Children = append(n.Children, child)
+}
+
+// Parse an HTML fragment or document into a tree the way browsers do,
+// malformed markup is repaired rather than rejected
+func Parse(src string) (root *Node) {
+	root = &Node{Tag: "#root"}
+
+	nodes, err := html.ParseFragment(strings.NewReader(escapeStrayLT(src)), fragmentContext)
+	if err != nil {
+		// reading from a string doesn't fail, keep the text just in case
+		root.appendChild(&Node{Text: src})
+		return root
+	}
+	for _, node := range nodes {
+		convertNode(root, node)
+	}
+
+	return root
+}
+
+// Escape a "<" that doesn't open a complete tag, e.g. in "if a<b then</p>".
+// Browsers would take it for a tag and hide the text up to the next ">".
+func escapeStrayLT(src string) string {
+	var sb strings.Builder
+	for idx := 0; idx < len(src); idx++ {
+		if src[idx] != '<' || !opensTag(src[idx+1:]) {
+			sb.WriteByte(src[idx])
+			continue
+		}
+		end := tagEnd(src[idx+1:])
+		if end < 0 {
+			sb.WriteString("&lt;")
+			continue
+		}
+		sb.WriteString(src[idx : idx+end+2])
+		idx += end + 1
+	}
+	return sb.String()
+}
+
+// Whether the text after a "<" starts a start or end tag
+func opensTag(rest string) bool {
+	rest = strings.TrimPrefix(rest, "/")
+	return rest != "" && (rest[0] >= 'a' && rest[0] <= 'z' || rest[0] >= 'A' && rest[0] <= 'Z')
+}
+
+// Position of the ">" closing a tag, or -1 if another "<" comes first.
+// Quoted attribute values may contain both.
+func tagEnd(rest string) int {
+	afterEquals := false
+	for idx := 0; idx < len(rest); idx++ {
+		switch char := rest[idx]; char {
+		case '>':
+			return idx
+		case '<':
+			return -1
+		case '"', '\'':
+			if !afterEquals {continue}
+			closing := strings.IndexByte(rest[idx+1:], char)
+			if closing < 0 {return -1}
+			idx += closing + 1
+			afterEquals = false
+		case '=':
+			afterEquals = true
+		case ' ', '\t', '\n', '\r', '\f':
+		default:
+			afterEquals = false
+		}
+	}
+	return -1
+}
+
+// Add a parsed node to the tree, comments and doctypes are left out
+func convertNode(parent *Node, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		parent.appendChild(&Node{Text: n.Data})
+	case html.ElementNode:
+		node := &Node{Tag: strings.ToLower(n.Data)}
+		for _, attr := range n.Attr {
+			node.Attrs = append(node.Attrs, xml.Attr{Name: xml.Name{Local: attr.Key}, Value: attr.Val})
+		}
+		parent.appendChild(node)
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			convertNode(node, child)
+		}
+	}
+}
+
+// Get the visible text of a node and its descendants
+func (n *Node) InnerText() string {
+	var sb strings.Builder
+	n.walkText(&sb)
+	return sb.String()
+}
+
+func (n *Node) walkText(sb *strings.Builder) {
+	if n.Tag == "" {
+		sb.WriteString(n.Text)
+		return
+	}
+	if rawTextTags[n.Tag] {return}
+
+	// keep words of neighbouring blocks apart
+	separated := blockTags[n.Tag] || n.Tag == "br" || n.Tag == "li"
+	if separated {
+		sb.WriteString(" ")
+	}
+	for _, child := range n.Children {
+		child.walkText(sb)
+	}
+	if separated {
+		sb.WriteString(" ")
+	}
+}
+
+// Convert HTML to a single line of plain text
+func Strip(src string) string {
+	return strings.Join(strings.Fields(Parse(src).InnerText()), " ")
+}
+
+// Serialize a node and its descendants back to HTML
+func (n *Node) HTML() string {
+	var sb strings.Builder
+	n.writeHTML(&sb)
+	return sb.String()
+}
+
+func (n *Node) writeHTML(sb *strings.Builder) {
+	if n.Tag == "" {
+		sb.WriteString(html.EscapeString(n.Text))
+		return
+	}
+
+	if n.Tag != "#root" {
+		sb.WriteString("<" + n.Tag)
+		for _, attr := range n.Attrs {
+			sb.WriteString(" " + attr.Name.Local + `="`)
+			sb.WriteString(html.EscapeString(attr.Value))
+			sb.WriteString(`"`)
+		}
+		sb.WriteString(">")
+		if voidTags[n.Tag] {return}
+	}
+
+	for _, child := range n.Children {
+		child.writeHTML(sb)
+	}
+
+	if n.Tag != "#root" {
+		sb.WriteString("</" + n.Tag + ">")
+	}
+}
+
+var voidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true, "wbr": true,
+}
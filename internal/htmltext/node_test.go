@@ -0,0 +1,49 @@
+package htmltext
+
+import "testing"
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		src string
+		want string
+	}{
+		{
+			"unquoted attributes and unclosed list items",
+			`<p>Intro</p><img src=a.png alt=pic><p>After <em>emph</em></p><ul><li>one<li>two</ul>`,
+			`<p>Intro</p><img src="a.png" alt="pic"><p>After <em>emph</em></p><ul><li>one</li><li>two</li></ul>`,
+		},
+		{"unclosed paragraphs", `<p>one<p>two`, `<p>one</p><p>two</p>`},
+		{"stray less-than sign", `<p>a < b and c</p>`, `<p>a &lt; b and c</p>`},
+		{"less-than sign before a letter", `<p>if a<b then</p>`, `<p>if a&lt;b then</p>`},
+		{"less-than sign at the end", `tail <b`, `tail &lt;b`},
+		{"less-than sign in a quoted attribute", `<img alt="a<b" src=x.png>`, `<img alt="a&lt;b" src="x.png">`},
+		{"whole document", `<!doctype html><html><head><title>T</title></head><body><p>text</p></body></html>`, `<p>text</p>`},
+		{"scripts and handlers", `<script>alert(1)</script><a href=javascript:alert(1) onclick=x>link</a>`, `<a>link</a>`},
+		{"preformatted text", "<pre>a\n  b</pre>", "<pre>a\n  b</pre>"},
+	}
+
+	for _, test := range tests {
+		if got := Sanitize(test.src); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		src string
+		want string
+	}{
+		{`<p>Intro</p><img src=a.png alt=pic><p>After <em>emph</em></p><ul><li>one<li>two</ul>`, "Intro After emph one two"},
+		{`<p>if a<b then</p>`, "if a<b then"},
+		{`1<2 &amp; 3>2`, "1<2 & 3>2"},
+		{`<style>p {}</style>Fish &amp; chips`, "Fish & chips"},
+	}
+
+	for _, test := range tests {
+		if got := Strip(test.src); got != test.want {
+			t.Errorf("Strip(%q): got %q, want %q", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package htmltext
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Elements that start a new block of text
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true,
+	"footer": true, "main": true, "aside": true, "figure": true, "figcaption": true,
+	"table": true, "tr": true, "dl": true, "dt": true, "dd": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+type renderer struct {
+	width int
+	out strings.Builder
+	line strings.Builder
+	indent []string
+	bullet string
+	links []string
+	pre int
+	// text was written since the last block ended
+	written bool
+	// an empty line is due before the next block
+	blank bool
+}
+
+
+// Render HTML as wrapped terminal text. Links are replaced by footnote
+// references listed after the text.
+func Render(src string, width int) string {
+	if width < 20 {
+		width = 20
+	}
+
+	r := &renderer{width: width}
+	r.walk(Parse(src))
+	r.flush()
+
+	text := strings.TrimRight(r.out.String(), "\n")
+	if len(r.links) > 0 {
+		text += "\n"
+		for idx, link := range r.links {
+			text += fmt.Sprintf("\n[%d] %s", idx+1, link)
+		}
+	}
+
+	return text
+}
+
+func (r *renderer) walk(n *Node) {
+	if n.Tag == "" {
+		r.line.WriteString(n.Text)
+		return
+	}
+	if droppedTags[n.Tag] || isTracker(n) {return}
+
+	switch {
+	case n.Tag == "br":
+		if r.pre > 0 {
+			r.line.WriteString("\n")
+			return
+		}
+		r.flush()
+	case n.Tag == "hr":
+		r.block()
+		r.startBlock()
+		r.out.WriteString(strings.Repeat("-", min(r.width, 40)) + "\n")
+		r.wrote()
+		r.block()
+	case n.Tag == "img":
+		if alt := strings.TrimSpace(n.Attr("alt")); alt != "" {
+			r.line.WriteString(" [image: " + alt + "] ")
+		}
+	case n.Tag == "a":
+		r.walkChildren(n)
+		href := strings.TrimSpace(n.Attr("href"))
+		if href != "" && safeURL(href) && !strings.HasPrefix(href, "#") {
+			r.links = append(r.links, href)
+			r.line.WriteString("[" + strconv.Itoa(len(r.links)) + "]")
+		}
+	case n.Tag == "em" || n.Tag == "i":
+		r.wrapInline(n, "_")
+	case n.Tag == "strong" || n.Tag == "b":
+		r.wrapInline(n, "*")
+	case n.Tag == "code" && r.pre == 0:
+		r.wrapInline(n, "`")
+	case n.Tag == "ul" || n.Tag == "ol":
+		r.list(n)
+	case n.Tag == "blockquote":
+		r.block()
+		r.startBlock()
+		r.indent = append(r.indent, "> ")
+		r.walkChildren(n)
+		r.block()
+		r.indent = r.indent[:len(r.indent)-1]
+	case n.Tag == "pre":
+		r.block()
+		r.pre++
+		r.walkChildren(n)
+		r.flushPre()
+		r.pre--
+		r.block()
+	case strings.HasPrefix(n.Tag, "h") && blockTags[n.Tag]:
+		r.block()
+		r.walkChildren(n)
+		r.upperLine()
+		r.block()
+	case blockTags[n.Tag]:
+		r.block()
+		r.walkChildren(n)
+		r.block()
+	default:
+		r.walkChildren(n)
+	}
+}
+
+func (r *renderer) walkChildren(n *Node) {
+	for _, child := range n.Children {
+		r.walk(child)
+	}
+}
+
+func (r *renderer) wrapInline(n *Node, mark string) {
+	text := strings.TrimSpace(strings.Join(strings.Fields(n.InnerText()), " "))
+	if text == "" {return}
+
+	r.line.WriteString(" " + mark)
+	r.walkChildren(n)
+	r.line.WriteString(mark + " ")
+}
+
+func (r *renderer) list(n *Node) {
+	r.flush()
+	ordered := n.Tag == "ol"
+	counter := 0
+
+	for _, item := range n.Children {
+		if item.Tag != "li" {continue}
+		counter++
+
+		bullet := "• "
+		if ordered {
+			bullet = strconv.Itoa(counter) + ". "
+		}
+
+		r.flush()
+		r.bullet = bullet
+		r.indent = append(r.indent, strings.Repeat(" ", utf8.RuneCountInString(bullet)))
+		r.walkChildren(item)
+		r.flush()
+		r.indent = r.indent[:len(r.indent)-1]
+	}
+
+	if len(r.indent) == 0 {
+		r.block()
+	}
+}
+
+// Finish the current paragraph and leave an empty line after it
+func (r *renderer) block() {
+	r.flush()
+	if r.written {
+		r.blank = true
+		r.written = false
+	}
+}
+
+// Write the empty line left by the previous block
+func (r *renderer) startBlock() {
+	if !r.blank {return}
+
+	r.out.WriteString(strings.TrimRight(strings.Join(r.indent, ""), " ") + "\n")
+	r.blank = false
+}
+
+// Mark the output as having content in the current block
+func (r *renderer) wrote() {
+	r.written = true
+}
+
+// Write the pending inline text wrapped to the terminal width
+func (r *renderer) flush() {
+	words := strings.Fields(r.line.String())
+	r.line.Reset()
+	if len(words) == 0 {return}
+
+	indent := strings.Join(r.indent, "")
+	first := indent
+	if r.bullet != "" {
+		// the bullet replaces the indentation of the innermost list level
+		first = strings.Join(r.indent[:len(r.indent)-1], "") + r.bullet
+		r.bullet = ""
+	}
+
+	r.startBlock()
+	prefix := first
+	lineLength := utf8.RuneCountInString(prefix)
+	r.out.WriteString(prefix)
+	started := false
+	for _, word := range words {
+		wordLength := utf8.RuneCountInString(word)
+		if started && lineLength+1+wordLength > r.width {
+			r.out.WriteString("\n" + indent)
+			lineLength = utf8.RuneCountInString(indent)
+			started = false
+		}
+		if started {
+			r.out.WriteString(" ")
+			lineLength++
+		}
+		r.out.WriteString(word)
+		lineLength += wordLength
+		started = true
+	}
+	r.out.WriteString("\n")
+	r.wrote()
+}
+
+// Write preformatted text as is
+func (r *renderer) flushPre() {
+	indent := strings.Join(r.indent, "")
+	text := strings.Trim(r.line.String(), "\n")
+	r.line.Reset()
+	if text == "" {return}
+
+	r.startBlock()
+	for _, line := range strings.Split(text, "\n") {
+		r.out.WriteString(indent + "    " + line + "\n")
+	}
+	r.wrote()
+}
+
+// Headings are shown in capitals
+func (r *renderer) upperLine() {
+	text := strings.ToUpper(r.line.String())
+	r.line.Reset()
+	r.line.WriteString(text)
+}
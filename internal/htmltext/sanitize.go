@@ -0,0 +1,90 @@
+package htmltext
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// Elements removed together with their content
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true,
+	"object": true, "embed": true, "applet": true, "form": true, "input": true,
+	"button": true, "select": true, "textarea": true, "link": true, "meta": true,
+	"base": true, "noscript": true, "template": true, "svg": true, "math": true,
+	"head": true, "title": true,
+}
+
+// Attributes kept on sanitized elements
+var allowedAttrs = map[string]bool{
+	"href": true, "src": true, "alt": true, "title": true,
+	"width": true, "height": true, "colspan": true, "rowspan": true,
+}
+
+// URL fragments typical for tracking pixels and analytics beacons
+var trackerHints = []string{
+	"feedburner.com/~r", "feeds.feedburner.com/~ff", "doubleclick.net",
+	"google-analytics.com", "pixel.", "/pixel", "/beacon", "/track", "stats.wp.com",
+}
+
+
+// Remove scripts, styles, embedded objects, event handlers, unsafe URLs and
+// tracking pixels from an HTML fragment
+func Sanitize(src string) string {
+	root := Parse(src)
+	sanitizeNode(root)
+	return root.HTML()
+}
+
+func sanitizeNode(n *Node) {
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		if child.Tag == "" {
+			children = append(children, child)
+			continue
+		}
+		if droppedTags[child.Tag] || isTracker(child) {continue}
+
+		child.Attrs = sanitizeAttrs(child.Attrs)
+		sanitizeNode(child)
+		children = append(children, child)
+	}
+	n.Children = children
+}
+
+func sanitizeAttrs(attrs []xml.Attr) (clean []xml.Attr) {
+	for _, attr := range attrs {
+		name := strings.ToLower(attr.Name.Local)
+		if !allowedAttrs[name] {continue}
+		if (name == "href" || name == "src") && !safeURL(attr.Value) {continue}
+
+		clean = append(clean, xml.Attr{Name: xml.Name{Local: name}, Value: attr.Value})
+	}
+	return clean
+}
+
+// Allow only web, mail and relative links
+func safeURL(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	scheme, _, found := strings.Cut(value, ":")
+	if !found || strings.ContainsAny(scheme, "/?#") {return true}
+
+	return scheme == "http" || scheme == "https" || scheme == "mailto"
+}
+
+// Tiny or hidden images and known analytics URLs
+func isTracker(n *Node) bool {
+	if n.Tag != "img" {return false}
+
+	width, errW := strconv.Atoi(strings.TrimSuffix(n.Attr("width"), "px"))
+	height, errH := strconv.Atoi(strings.TrimSuffix(n.Attr("height"), "px"))
+	if (errW == nil && width <= 1) || (errH == nil && height <= 1) {return true}
+
+	src := strings.ToLower(n.Attr("src"))
+	if src == "" {return true}
+	for _, hint := range trackerHints {
+		if strings.Contains(src, hint) {return true}
+	}
+
+	return false
+}
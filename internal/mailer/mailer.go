@@ -0,0 +1,214 @@
+// Package mailer builds multipart emails and sends them over SMTP.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Server settings
+type Options struct {
+	Host string
+	// 587 by default, or 465 with ImplicitTLS
+	Port int
+	Username string
+	Password string
+	// connect over TLS right away instead of upgrading the connection with STARTTLS
+	ImplicitTLS bool
+	Timeout time.Duration
+}
+
+// An email with a plain text and an HTML version of the body
+type Message struct {
+	From string
+	To []string
+	Subject string
+	Date time.Time
+	// generated if empty
+	MessageID string
+	// extra headers in the given order
+	Headers [][2]string
+	Text string
+	HTML string
+}
+
+var ErrNoRecipients = errors.New("message has no recipients")
+
+
+func (o Options) addr() string {
+	port := o.Port
+	if port == 0 {
+		port = 587
+		if o.ImplicitTLS {
+			port = 465
+		}
+	}
+	return net.JoinHostPort(o.Host, strconv.Itoa(port))
+}
+
+// Encode the message as multipart/alternative with quoted-printable parts
+func (m Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", m.From, err)
+	}
+	to := make([]string, len(m.To))
+	for idx, recipient := range m.To {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+		}
+		to[idx] = address.String()
+	}
+
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	id := m.MessageID
+	if id == "" {
+		id = messageID(from.Address)
+	}
+
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+	headers := [][2]string{{"From", from.String()}}
+	if len(to) > 0 {
+		headers = append(headers, [2]string{"To", strings.Join(to, ", ")})
+	}
+	headers = append(headers, [][2]string{
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", id},
+	}...)
+	for _, header := range m.Headers {
+		headers = append(headers, [2]string{header[0], mime.QEncoding.Encode("utf-8", header[1])})
+	}
+	headers = append(headers, [][2]string{
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + body.Boundary()},
+	}...)
+
+	var message bytes.Buffer
+	for _, header := range headers {
+		fmt.Fprintf(&message, "%s: %s\r\n", header[0], header[1])
+	}
+	message.WriteString("\r\n")
+
+	parts := []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	}
+	for _, part := range parts {
+		writer, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {return nil, err}
+
+		encoder := quotedprintable.NewWriter(writer)
+		_, err = encoder.Write([]byte(part.content))
+		if err != nil {return nil, err}
+		err = encoder.Close()
+		if err != nil {return nil, err}
+	}
+	err = body.Close()
+	if err != nil {return nil, err}
+
+	message.Write(buf.Bytes())
+	return message.Bytes(), err
+}
+
+// Unique message ID in the sender's domain
+func messageID(sender string) string {
+	_, domain, found := strings.Cut(sender, "@")
+	if !found {
+		domain = "localhost"
+	}
+
+	random := make([]byte, 12)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().Unix(), hex.EncodeToString(random), domain)
+}
+
+// Send the message, STARTTLS is used when the server supports it.
+// Credentials are only sent over TLS or to a server on localhost.
+func Send(opts Options, m Message) (err error) {
+	if len(m.To) == 0 {
+		return ErrNoRecipients
+	}
+	msg, err := m.Bytes()
+	if err != nil {return}
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {return}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	if opts.ImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", opts.addr(), &tls.Config{ServerName: opts.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", opts.addr())
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't connect to %s: %w", opts.addr(), err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	client, err := smtp.NewClient(conn, opts.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !opts.ImplicitTLS {
+		err = client.StartTLS(&tls.Config{ServerName: opts.Host})
+		if err != nil {return}
+	}
+
+	if opts.Username != "" {
+		// PlainAuth refuses to send credentials over plain text connections to remote hosts
+		err = client.Auth(smtp.PlainAuth("", opts.Username, opts.Password, opts.Host))
+		if err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	err = client.Mail(from.Address)
+	if err != nil {return}
+	for _, recipient := range m.To {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {return err}
+		err = client.Rcpt(address.Address)
+		if err != nil {return err}
+	}
+
+	writer, err := client.Data()
+	if err != nil {return}
+	_, err = writer.Write(msg)
+	if err != nil {return}
+	err = writer.Close()
+	if err != nil {return}
+
+	return client.Quit()
+}
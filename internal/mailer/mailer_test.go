@@ -0,0 +1,179 @@
+package mailer
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// What a fake SMTP server received in a session
+type received struct {
+	from string
+	to []string
+	data []byte
+	err error
+}
+
+// Accept a single SMTP session without extensions on a local port
+func fakeSMTP(t *testing.T) (port int, session chan received) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {t.Fatal(err)}
+	t.Cleanup(func() {listener.Close()})
+
+	session = make(chan received, 1)
+	go func() {
+		var got received
+		defer func() {session <- got}()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			got.err = err
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost fake SMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				got.err = err
+				return
+			}
+			command, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(command) {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL":
+				got.from = arg
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				got.to = append(got.to, arg)
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				got.data, got.err = text.ReadDotBytes()
+				if got.err != nil {return}
+				text.PrintfLine("250 queued")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, session
+}
+
+func testMessage() Message {
+	return Message{
+		From: "Gator <gator@example.com>",
+		To: []string{"Reader <reader@example.com>"},
+		Subject: "Gator digest: 2 new posts – café",
+		Date: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Headers: [][2]string{{"X-Feed-Name", "Example"}},
+		Text: "Plain body with a long line " + strings.Repeat("x", 100),
+		HTML: "<p>HTML body</p>",
+	}
+}
+
+// Parse an encoded message and check its headers and both parts
+func checkMessage(t *testing.T, data []byte) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {t.Fatalf("invalid message: %v", err)}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != testMessage().Subject {
+		t.Errorf("got subject %q (%v)", subject, err)
+	}
+	if from := msg.Header.Get("From"); from != `"Gator" <gator@example.com>` {
+		t.Errorf("got From %q", from)
+	}
+	if to := msg.Header.Get("To"); to != `"Reader" <reader@example.com>` {
+		t.Errorf("got To %q", to)
+	}
+	if date := msg.Header.Get("Date"); date != "Wed, 01 May 2024 10:00:00 +0000" {
+		t.Errorf("got Date %q", date)
+	}
+	if id := msg.Header.Get("Message-ID"); !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("got Message-ID %q", id)
+	}
+	if feed := msg.Header.Get("X-Feed-Name"); feed != "Example" {
+		t.Errorf("got X-Feed-Name %q", feed)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("got content type %q (%v)", mediaType, err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	want := []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", testMessage().Text},
+		{"text/html; charset=utf-8", testMessage().HTML},
+	}
+	for _, wanted := range want {
+		part, err := parts.NextRawPart()
+		if err != nil {t.Fatalf("missing %s part: %v", wanted.contentType, err)}
+		if contentType := part.Header.Get("Content-Type"); contentType != wanted.contentType {
+			t.Errorf("got part of type %q, want %q", contentType, wanted.contentType)
+		}
+		content, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil || string(content) != wanted.content {
+			t.Errorf("got %s content %q (%v)", wanted.contentType, content, err)
+		}
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("unexpected extra part: %v", err)
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	data, err := testMessage().Bytes()
+	if err != nil {t.Fatal(err)}
+	checkMessage(t, data)
+
+	msg := testMessage()
+	msg.From = "not an address"
+	_, err = msg.Bytes()
+	if err == nil {
+		t.Error("a message with an invalid sender was encoded")
+	}
+}
+
+func TestSend(t *testing.T) {
+	port, session := fakeSMTP(t)
+
+	err := Send(Options{Host: "127.0.0.1", Port: port, Timeout: 5 * time.Second}, testMessage())
+	if err != nil {t.Fatalf("send: %v", err)}
+
+	got := <-session
+	if got.err != nil {t.Fatalf("fake SMTP server: %v", got.err)}
+	if got.from != "FROM:<gator@example.com>" {
+		t.Errorf("got MAIL %q", got.from)
+	}
+	if len(got.to) != 1 || got.to[0] != "TO:<reader@example.com>" {
+		t.Errorf("got RCPT %q", got.to)
+	}
+	checkMessage(t, got.data)
+}
+
+func TestSendWithoutRecipients(t *testing.T) {
+	msg := testMessage()
+	msg.To = nil
+	err := Send(Options{Host: "127.0.0.1", Port: 1}, msg)
+	if !errors.Is(err, ErrNoRecipients) {
+		t.Errorf("got %v, want ErrNoRecipients", err)
+	}
+}
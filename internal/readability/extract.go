@@ -0,0 +1,150 @@
+package readability
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/DIVIgor/gator/internal/htmltext"
+)
+
+var ErrNoContent = errors.New("no article content found")
+
+// Minimal length of a paragraph worth scoring
+const minParagraphLength int = 25
+
+var positiveHints = regexp.MustCompile(`(?i)article|body|content|entry|hentry|main|page|post|text|blog|story`)
+var negativeHints = regexp.MustCompile(`(?i)comment|meta|footer|footnote|sidebar|widget|nav|menu|share|social|promo|related|sponsor|banner|advert|(^|\W)ad(s)?(\W|$)|popup|cookie|subscribe|newsletter`)
+
+// Page chrome that is never a part of the article
+var skippedTags = map[string]bool{
+	"nav": true, "header": true, "footer": true, "aside": true, "form": true,
+	"button": true, "menu": true, "dialog": true,
+}
+
+// Elements that carry the text of an article
+var paragraphTags = map[string]bool{"p": true, "pre": true, "td": true, "blockquote": true}
+
+
+// Extract the main article content from an HTML page using a readability-style
+// heuristic: paragraphs give points to their ancestors, the container with the
+// best score weighted by its link density wins
+func Extract(page string) (article string, err error) {
+	root := htmltext.Parse(page)
+	prune(root)
+
+	scores := map[*htmltext.Node]float64{}
+	var paragraphs []*htmltext.Node
+	collectParagraphs(root, &paragraphs)
+
+	for _, paragraph := range paragraphs {
+		text := strings.Join(strings.Fields(paragraph.InnerText()), " ")
+		if len(text) < minParagraphLength {continue}
+
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+
+		parent := paragraph.Parent
+		if parent == nil {continue}
+		addScore(scores, parent, score)
+		if parent.Parent != nil {
+			addScore(scores, parent.Parent, score/2)
+		}
+	}
+
+	var best *htmltext.Node
+	bestScore := 0.0
+	for node, score := range scores {
+		score *= 1 - linkDensity(node)
+		if score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	if best == nil {
+		return article, ErrNoContent
+	}
+
+	return htmltext.Sanitize(best.HTML()), err
+}
+
+// Drop navigation, forms and elements hinting at non-article content
+func prune(n *htmltext.Node) {
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		if child.Tag != "" && (skippedTags[child.Tag] || isUnlikely(child)) {continue}
+
+		prune(child)
+		children = append(children, child)
+	}
+	n.Children = children
+}
+
+func isUnlikely(n *htmltext.Node) bool {
+	if n.Tag == "body" || n.Tag == "article" || n.Tag == "main" {return false}
+
+	hints := n.Attr("class") + " " + n.Attr("id")
+	return negativeHints.MatchString(hints) && !positiveHints.MatchString(hints)
+}
+
+func collectParagraphs(n *htmltext.Node, paragraphs *[]*htmltext.Node) {
+	for _, child := range n.Children {
+		if paragraphTags[child.Tag] {
+			*paragraphs = append(*paragraphs, child)
+			continue
+		}
+		collectParagraphs(child, paragraphs)
+	}
+}
+
+// Add points to a candidate, initializing it by its tag and class/id hints
+func addScore(scores map[*htmltext.Node]float64, n *htmltext.Node, score float64) {
+	if _, exists := scores[n]; !exists {
+		scores[n] = initialScore(n)
+	}
+	scores[n] += score
+}
+
+func initialScore(n *htmltext.Node) (score float64) {
+	switch n.Tag {
+	case "article", "main":
+		score += 10
+	case "div":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "form", "ol", "ul", "dl", "li", "th":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		score -= 5
+	}
+
+	hints := n.Attr("class") + " " + n.Attr("id")
+	if positiveHints.MatchString(hints) {
+		score += 25
+	}
+	if negativeHints.MatchString(hints) {
+		score -= 25
+	}
+
+	return score
+}
+
+// Share of the text that belongs to links
+func linkDensity(n *htmltext.Node) float64 {
+	textLength := len(strings.Join(strings.Fields(n.InnerText()), " "))
+	if textLength == 0 {return 1}
+
+	linkLength := 0
+	var walk func(node *htmltext.Node)
+	walk = func(node *htmltext.Node) {
+		for _, child := range node.Children {
+			if child.Tag == "a" {
+				linkLength += len(strings.Join(strings.Fields(child.InnerText()), " "))
+				continue
+			}
+			walk(child)
+		}
+	}
+	walk(n)
+
+	return float64(linkLength) / float64(textLength)
+}
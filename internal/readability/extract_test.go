@@ -0,0 +1,41 @@
+package readability
+
+import (
+	"strings"
+	"testing"
+)
+
+// A minified page the way many sites serve it, with unquoted attributes and optional tags left out
+const testPage string = `<!doctype html><html lang=en><meta charset=utf-8><meta name=viewport content=width=device-width><title>Migrating the birds</title>` +
+	`<link rel=stylesheet href=/style.css><body class=home><header class=site-header><a href=/>Home</a> <a href=/about>About</a></header>` +
+	`<nav class=menu><ul><li><a href=/archive>Archive</a><li><a href=/tags>Tags</a></ul></nav>` +
+	`<article class=post><h1>Migrating the birds</h1>` +
+	`<p>Every autumn, millions of birds leave the north, crossing seas and deserts on their way to warmer places.` +
+	`<p>Scientists track them with tiny tags, weather radars and, more and more often, with volunteers counting flocks at dawn.` +
+	`<img src=flock.jpg alt=flock width=600 height=400>` +
+	`<p>The data shows that the routes shift as the climate changes, some species now stay for the winter.</article>` +
+	`<aside class=sidebar><p>Subscribe to the newsletter to get the new posts, it's free and weekly, we promise.</aside>` +
+	`<footer class=site-footer><p>© 2024 Example, all rights reserved, no part may be copied.</footer>`
+
+func TestExtract(t *testing.T) {
+	article, err := Extract(testPage)
+	if err != nil {t.Fatalf("extract: %v", err)}
+
+	for _, want := range []string{"Every autumn, millions of birds", "with volunteers counting flocks", `<img src="flock.jpg" alt="flock" width="600" height="400">`, "some species now stay"} {
+		if !strings.Contains(article, want) {
+			t.Errorf("article lacks %q: %s", want, article)
+		}
+	}
+	for _, unwanted := range []string{"Archive", "newsletter", "rights reserved", "charset"} {
+		if strings.Contains(article, unwanted) {
+			t.Errorf("article contains %q: %s", unwanted, article)
+		}
+	}
+}
+
+func TestExtractWithoutArticle(t *testing.T) {
+	_, err := Extract(`<html lang=en><meta charset=utf-8><nav><a href=/>Home</a></nav>`)
+	if err != ErrNoContent {
+		t.Errorf("got %v, want ErrNoContent", err)
+	}
+}
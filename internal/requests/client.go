@@ -2,21 +2,105 @@ package requests
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 )
 
+const maxRedirects int = 10
+
 type Client struct {
 	httpClient http.Client
+	policy policy
+	options Options
+	// clients with per-feed TLS settings by their options
+	tlsClients *sync.Map
+}
+
+// Web client settings
+type Options struct {
+	Timeout time.Duration
+	// hosts, IPs or CIDR ranges allowed despite being internal
+	Allowlist []string
+	// proxy for all requests. HTTPS_PROXY, HTTP_PROXY and NO_PROXY are used if empty
+	ProxyURL string
+	TLS TLSOptions
 }
 
-func NewClient(timeout time.Duration) Client {
-	return Client{
-		httpClient: http.Client{
-			Timeout: timeout,
+// Create a client that refuses to connect to loopback, link-local and private
+// addresses unless they are explicitly allowed by the allowlist
+func NewClient(opts Options) (client Client, err error) {
+	proxy, proxyHosts, err := proxyFunc(opts.ProxyURL)
+	if err != nil {return}
+
+	// the proxy is trusted for connections, the targets are checked before sending requests to it
+	p, err := newPolicy(opts.Allowlist, proxyHosts)
+	if err != nil {return}
+
+	client = Client{
+		policy: p,
+		options: opts,
+		tlsClients: &sync.Map{},
+	}
+	client.httpClient, err = client.newHTTPClient(opts.TLS, proxy)
+
+	return client, err
+}
+
+func (c *Client) newHTTPClient(tlsOpts TLSOptions, proxy func(*http.Request) (*url.URL, error)) (httpClient http.Client, err error) {
+	tlsConfig, err := tlsOpts.config()
+	if err != nil {return}
+
+	dialer := &net.Dialer{Timeout: c.options.Timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.DialContext = c.policy.dialContext(dialer)
+	transport.TLSClientConfig = tlsConfig
+
+	return http.Client{
+		Timeout: c.options.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("stopped after too many redirects")
+			}
+			stripSettings(req, via)
+			return c.checkTarget(req)
 		},
+	}, err
+}
+
+// Get the HTTP client for the TLS overrides of a request
+func (c *Client) clientFor(tlsOpts TLSOptions) (httpClient *http.Client, err error) {
+	if tlsOpts.isZero() {
+		return &c.httpClient, err
 	}
+
+	cached, exists := c.tlsClients.Load(tlsOpts)
+	if exists {
+		return cached.(*http.Client), err
+	}
+
+	transport := c.httpClient.Transport.(*http.Transport)
+	derived, err := c.newHTTPClient(c.options.TLS.merge(tlsOpts), transport.Proxy)
+	if err != nil {return}
+
+	cached, _ = c.tlsClients.LoadOrStore(tlsOpts, &derived)
+	return cached.(*http.Client), err
+}
+
+// Check the URL of a request and the addresses of the target host. Behind a proxy
+// the dial check only sees the proxy, and a target on the proxy host would pass it.
+func (c *Client) checkTarget(req *http.Request) (err error) {
+	err = c.policy.checkURL(req.URL)
+	if err != nil {return}
+
+	return c.policy.checkHost(req.Context(), req.URL.Hostname())
 }
 
 func newRequest(ctx context.Context, method, url string, body io.Reader) (req *http.Request, err error) {
@@ -29,8 +113,25 @@ func newRequest(ctx context.Context, method, url string, body io.Reader) (req *h
 }
 
 func (c *Client) MakeRequest(ctx context.Context, method, url string, body io.Reader) (resp *http.Response, err error) {
+	return c.MakeRequestWith(ctx, method, url, body, Settings{})
+}
+
+// Make a request with extra headers, credentials, query parameters or TLS settings
+func (c *Client) MakeRequestWith(ctx context.Context, method, url string, body io.Reader, settings Settings) (resp *http.Response, err error) {
 	req, err := newRequest(ctx, method, url, body)
 	if err != nil {return}
 
-	return c.httpClient.Do(req)
-}
\ No newline at end of file
+	req = settings.apply(req)
+
+	err = c.checkTarget(req)
+	if err != nil {
+		return nil, fmt.Errorf("refusing to fetch: %w", err)
+	}
+
+	httpClient, err := c.clientFor(settings.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS settings: %w", err)
+	}
+
+	return httpClient.Do(req)
+}
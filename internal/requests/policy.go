@@ -0,0 +1,157 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+var ErrBlockedAddress = errors.New("address is not allowed")
+var ErrBlockedScheme = errors.New("only http and https URLs are allowed")
+
+// Networks outside of the IsPrivate/IsLoopback helpers that shouldn't be reachable either
+var extraBlockedNets = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+)
+
+// Decides which hosts and addresses the client may connect to
+type policy struct {
+	hosts map[string]bool
+	nets  []*net.IPNet
+	// proxies may be connected to but aren't allowed as request targets
+	proxyHosts map[string]bool
+}
+
+func mustParseCIDRs(cidrs ...string) (nets []*net.IPNet) {
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {panic(err)}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// Build a policy from allowlist entries: host names, IPs or CIDR ranges
+func newPolicy(allowlist, proxyHosts []string) (p policy, err error) {
+	p.hosts = map[string]bool{}
+	p.proxyHosts = map[string]bool{}
+	for _, host := range proxyHosts {
+		p.proxyHosts[strings.ToLower(host)] = true
+	}
+	for _, entry := range allowlist {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {continue}
+
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return p, fmt.Errorf("invalid allowlist entry %q: %w", entry, err)
+			}
+			p.nets = append(p.nets, ipNet)
+			continue
+		}
+
+		if ip := net.ParseIP(entry); ip != nil {
+			p.nets = append(p.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+
+		p.hosts[entry] = true
+	}
+
+	return p, err
+}
+
+func (p policy) hostAllowed(host string) bool {
+	return p.hosts[strings.ToLower(strings.TrimSuffix(host, "."))]
+}
+
+func (p policy) ipAllowed(ip net.IP) bool {
+	for _, ipNet := range p.nets {
+		if ipNet.Contains(ip) {return true}
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, ipNet := range extraBlockedNets {
+		if ipNet.Contains(ip) {return false}
+	}
+
+	return true
+}
+
+// Check the scheme of a URL before any connection is made
+func (p policy) checkURL(u *url.URL) (err error) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: %q", ErrBlockedScheme, u.String())
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: missing host in %q", ErrBlockedAddress, u.String())
+	}
+
+	return err
+}
+
+// Check an IP host or all the addresses a host name resolves to
+func (p policy) checkHost(ctx context.Context, host string) (err error) {
+	if p.hostAllowed(host) {return}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !p.ipAllowed(ip) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+		}
+		return err
+	}
+
+	// names that don't resolve locally are refused since the proxy could resolve
+	// them to internal addresses, such hosts have to be allowlisted
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: couldn't resolve %s: %v", ErrBlockedAddress, host, err)
+	}
+
+	for _, addr := range addrs {
+		if !p.ipAllowed(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, addr.IP)
+		}
+	}
+
+	return err
+}
+
+// Dial wrapper that verifies the address after DNS resolution
+func (p policy) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {return}
+
+		if p.hostAllowed(host) || p.proxyHosts[strings.ToLower(host)] {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		// the control hook runs for every resolved address right before connecting
+		guarded := *dialer
+		guarded.Control = func(network, address string, _ syscall.RawConn) error {
+			ipStr, _, err := net.SplitHostPort(address)
+			if err != nil {return err}
+
+			ip := net.ParseIP(ipStr)
+			if ip == nil || !p.ipAllowed(ip) {
+				return fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, ipStr)
+			}
+			return nil
+		}
+
+		return guarded.DialContext(ctx, network, addr)
+	}
+}
@@ -0,0 +1,86 @@
+package requests
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Build the proxy selector and list the proxy hosts the client has to reach.
+// Without an explicit proxy URL the standard environment variables are used.
+func proxyFunc(proxyURL string) (proxy func(*http.Request) (*url.URL, error), hosts []string, err error) {
+	if proxyURL == "" {
+		for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+			if host := proxyHost(os.Getenv(name)); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+		return http.ProxyFromEnvironment, hosts, err
+	}
+
+	parsed, err := url.Parse(proxyURL)
+	if err != nil || parsed.Host == "" {
+		return nil, nil, fmt.Errorf("invalid proxy URL %q", proxyURL)
+	}
+
+	noProxy := getEnvAny("NO_PROXY", "no_proxy")
+	proxy = func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		return parsed, nil
+	}
+
+	return proxy, []string{parsed.Hostname()}, err
+}
+
+func proxyHost(rawURL string) string {
+	if rawURL == "" {return ""}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {return ""}
+	return parsed.Hostname()
+}
+
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Match a host against a NO_PROXY list of domains, IPs and CIDR ranges
+func bypassProxy(host, noProxy string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {continue}
+		if entry == "*" {return true}
+
+		// ports are ignored
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && ipNet.Contains(ip) {return true}
+			continue
+		}
+
+		entry = strings.TrimPrefix(entry, "*")
+		domain := strings.TrimPrefix(entry, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {return true}
+	}
+
+	return false
+}
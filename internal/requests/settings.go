@@ -0,0 +1,61 @@
+package requests
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Per-request additions such as authentication
+type Settings struct {
+	Header http.Header
+	Username string
+	Password string
+	Query url.Values
+	// overrides of the client TLS settings
+	TLS TLSOptions
+}
+
+// Context key of the settings applied to a request
+type settingsKey struct{}
+
+
+// Add the settings to a request, the returned request carries them for redirects
+func (s Settings) apply(req *http.Request) *http.Request {
+	for name, values := range s.Header {
+		req.Header.Del(name)
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	if s.Username != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+
+	if len(s.Query) > 0 {
+		query := req.URL.Query()
+		for name, values := range s.Query {
+			query[name] = values
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), settingsKey{}, s))
+}
+
+// Drop the credentials of the settings from a request redirected to another host.
+// net/http only strips Authorization and Cookie, custom headers would be sent along.
+func stripSettings(req *http.Request, via []*http.Request) {
+	if len(via) == 0 || req.URL.Host == via[0].URL.Host {return}
+
+	s, ok := req.Context().Value(settingsKey{}).(Settings)
+	if !ok {return}
+
+	for name := range s.Header {
+		req.Header.Del(name)
+	}
+	if s.Username != "" {
+		req.Header.Del("Authorization")
+	}
+}
@@ -0,0 +1,73 @@
+package requests
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLS settings of the client or of a single feed
+type TLSOptions struct {
+	// PEM bundle with CAs trusted in addition to the system ones
+	CAFile string
+	ClientCertFile string
+	ClientKeyFile string
+	InsecureSkipVerify bool
+}
+
+
+func (o TLSOptions) isZero() bool {
+	return o == TLSOptions{}
+}
+
+// Apply per-feed overrides on top of the client settings
+func (o TLSOptions) merge(override TLSOptions) TLSOptions {
+	if override.CAFile != "" {
+		o.CAFile = override.CAFile
+	}
+	if override.ClientCertFile != "" {
+		o.ClientCertFile, o.ClientKeyFile = override.ClientCertFile, override.ClientKeyFile
+	}
+	o.InsecureSkipVerify = o.InsecureSkipVerify || override.InsecureSkipVerify
+
+	return o
+}
+
+func (o TLSOptions) config() (cfg *tls.Config, err error) {
+	cfg = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		pemData, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no certificates found in %s", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if o.ClientCertFile != "" || o.ClientKeyFile != "" {
+		if o.ClientCertFile == "" || o.ClientKeyFile == "" {
+			return nil, errors.New("both client certificate and key files are required")
+		}
+
+		cert, err := tls.LoadX509KeyPair(o.ClientCertFile, o.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, err
+}
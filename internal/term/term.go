@@ -0,0 +1,160 @@
+// Package term switches the terminal to raw mode and reads key presses
+// using stty and ANSI escape sequences.
+package term
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+)
+
+// Escape sequences for drawing a full-screen interface
+const (
+	EnterScreen = "\033[?1049h\033[?25l"
+	LeaveScreen = "\033[?25h\033[?1049l"
+	Home = "\033[H"
+	ClearLine = "\033[K"
+	Reverse = "\033[7m"
+	Bold = "\033[1m"
+	Reset = "\033[0m"
+)
+
+// Named keys, other keys are reported as the typed character
+const (
+	KeyUp = "up"
+	KeyDown = "down"
+	KeyLeft = "left"
+	KeyRight = "right"
+	KeyPageUp = "pgup"
+	KeyPageDown = "pgdown"
+	KeyHome = "home"
+	KeyEnd = "end"
+	KeyEnter = "enter"
+	KeyTab = "tab"
+	KeyBackspace = "backspace"
+	KeyEscape = "esc"
+	KeyCtrlC = "ctrl+c"
+)
+
+var ErrNotTerminal = errors.New("standard input is not a terminal")
+
+var escapeKeys = map[string]string{
+	"[A": KeyUp, "OA": KeyUp,
+	"[B": KeyDown, "OB": KeyDown,
+	"[C": KeyRight, "OC": KeyRight,
+	"[D": KeyLeft, "OD": KeyLeft,
+	"[5~": KeyPageUp,
+	"[6~": KeyPageDown,
+	"[H": KeyHome, "[1~": KeyHome, "OH": KeyHome,
+	"[F": KeyEnd, "[4~": KeyEnd, "OF": KeyEnd,
+}
+
+
+// Run stty on the controlling terminal
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNotTerminal, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Switch the terminal to raw mode, returns a function restoring the previous mode
+func MakeRaw() (restore func() error, err error) {
+	saved, err := stty("-g")
+	if err != nil {return}
+
+	_, err = stty("raw", "-echo")
+	if err != nil {return}
+
+	return func() error {
+		_, err := stty(saved)
+		return err
+	}, err
+}
+
+// Terminal size in columns and rows
+func Size() (width, height int, err error) {
+	size, err := stty("size")
+	if err != nil {return}
+
+	_, err = fmt.Sscan(size, &height, &width)
+	return width, height, err
+}
+
+// Read key presses until the reader fails, the channel is closed then
+func ReadKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	reader := bufio.NewReader(r)
+	for {
+		key, err := readKey(reader)
+		if err != nil {return}
+		if key != "" {
+			keys <- key
+		}
+	}
+}
+
+func readKey(reader *bufio.Reader) (key string, err error) {
+	char, _, err := reader.ReadRune()
+	if err != nil {return}
+
+	switch char {
+	case '\r', '\n':
+		return KeyEnter, err
+	case '\t':
+		return KeyTab, err
+	case 127, '\b':
+		return KeyBackspace, err
+	case 3:
+		return KeyCtrlC, err
+	case 27:
+		return readEscape(reader)
+	}
+
+	if char == utf8.RuneError || char < ' ' {
+		return "", err
+	}
+	return string(char), err
+}
+
+// Escape sequences arrive in one piece, a lone escape is the Esc key
+func readEscape(reader *bufio.Reader) (key string, err error) {
+	if reader.Buffered() == 0 {
+		return KeyEscape, err
+	}
+
+	var seq strings.Builder
+	for reader.Buffered() > 0 && seq.Len() < 8 {
+		char, err := reader.ReadByte()
+		if err != nil {return "", err}
+		seq.WriteByte(char)
+		// sequences end with a letter or a tilde
+		if seq.Len() > 1 && (char == '~' || (char >= 'A' && char <= 'Z') || (char >= 'a' && char <= 'z')) {
+			break
+		}
+	}
+
+	return escapeKeys[seq.String()], err
+}
+
+// Cut or pad text to exactly a number of columns
+func Fit(text string, width int) string {
+	if width <= 0 {return ""}
+
+	runes := []rune(text)
+	if len(runes) > width {
+		if width == 1 {
+			return "…"
+		}
+		return string(runes[:width-1]) + "…"
+	}
+	return text + strings.Repeat(" ", width-len(runes))
+}
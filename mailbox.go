@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DIVIgor/gator/internal/database"
+	"github.com/DIVIgor/gator/internal/htmltext"
+	"github.com/DIVIgor/gator/internal/mailer"
+)
+
+const defaultMailboxLimit int = 1000
+const mailboxTextWidth int = 72
+const mailboxSender string = "gator@localhost"
+// File with the message IDs written by earlier exports
+const mailboxSyncSuffix string = ".gator-sync"
+
+// Lines that mail readers would take for the start of a new message in mbox
+var mboxFromLine = regexp.MustCompile(`(?m)^(>*From )`)
+
+
+// Exported message IDs by folder, folders are empty in mbox files
+type mailboxSync struct {
+	path string
+	seen map[string]bool
+	added []string
+}
+
+func loadMailboxSync(path string) (sync *mailboxSync, err error) {
+	sync = &mailboxSync{path: path, seen: map[string]bool{}}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return sync, nil
+	}
+	if err != nil {return}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		sync.seen[scanner.Text()] = true
+	}
+	return sync, scanner.Err()
+}
+
+func (m *mailboxSync) has(folder, messageID string) bool {
+	return m.seen[folder+"\t"+messageID]
+}
+
+func (m *mailboxSync) add(folder, messageID string) {
+	key := folder + "\t" + messageID
+	m.seen[key] = true
+	m.added = append(m.added, key)
+}
+
+// Append the message IDs written in this run
+func (m *mailboxSync) save() (err error) {
+	if len(m.added) == 0 {return}
+
+	file, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {return}
+	_, err = file.WriteString(strings.Join(m.added, "\n") + "\n")
+	if err != nil {
+		file.Close()
+		return err
+	}
+	m.added = nil
+	return file.Close()
+}
+
+// Message-ID of a post derived from its feed and GUID, or its URL for feeds without GUIDs.
+// GUIDs are only unique within a feed
+func postMessageID(feedID int32, guid sql.NullString, url string) string {
+	source := url
+	if guid.Valid && guid.String != "" {
+		source = guid.String
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\n%s", feedID, source)))
+	return fmt.Sprintf("<%s@gator>", hex.EncodeToString(sum[:16]))
+}
+
+// A post as an email from its feed
+func postMessage(post database.GetPostsForUserRow) mailer.Message {
+	content := htmltext.Sanitize(postHTML(post))
+	text := post.Title + "\n" + post.Url + "\n\n" + htmltext.Render(content, mailboxTextWidth) + "\n"
+	body := fmt.Sprintf(`<!DOCTYPE html><html><body><h1><a href="%s">%s</a></h1>%s</body></html>`,
+		html.EscapeString(post.Url), html.EscapeString(post.Title), content)
+
+	return mailer.Message{
+		From: (&mail.Address{Name: post.FeedName, Address: mailboxSender}).String(),
+		Subject: post.Title,
+		Date: post.PublishedAt,
+		MessageID: postMessageID(post.FeedID, post.Guid, post.Url),
+		Headers: [][2]string{{"X-Feed-Name", post.FeedName}, {"X-Post-URL", post.Url}},
+		Text: text,
+		HTML: body,
+	}
+}
+
+// Mailboxes keep line feeds on disk
+func unixLines(msg []byte) []byte {
+	return []byte(strings.ReplaceAll(string(msg), "\r\n", "\n"))
+}
+
+// Append a message to an mbox file, quoting From lines the mboxrd way
+func appendMbox(file *os.File, date time.Time, msg []byte) (err error) {
+	body := mboxFromLine.ReplaceAll(unixLines(msg), []byte(">$1"))
+	if !strings.HasSuffix(string(body), "\n") {
+		body = append(body, '\n')
+	}
+
+	_, err = fmt.Fprintf(file, "From %s %s\n%s\n", mailboxSender, date.UTC().Format(time.ANSIC), body)
+	return err
+}
+
+// Create a maildir with its cur, new and tmp folders
+func makeMaildir(dir string) (err error) {
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		err = os.MkdirAll(filepath.Join(dir, sub), 0700)
+		if err != nil {return}
+	}
+	return err
+}
+
+// Deliver a message to a maildir, it's written to tmp and moved to new when complete
+func deliverMaildir(dir, messageID string, msg []byte) (err error) {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	host = strings.NewReplacer("/", "\\057", ":", "\\072").Replace(host)
+	sum := sha256.Sum256([]byte(messageID))
+	name := fmt.Sprintf("%d.P%dQ%s.%s", time.Now().Unix(), os.Getpid(), hex.EncodeToString(sum[:8]), host)
+
+	tmpPath := filepath.Join(dir, "tmp", name)
+	err = os.WriteFile(tmpPath, unixLines(msg), 0600)
+	if err != nil {return}
+
+	return os.Rename(tmpPath, filepath.Join(dir, "new", name))
+}
+
+// Folder names can't contain path separators or start with a dot
+func maildirFolderName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		return "untitled"
+	}
+	return name
+}
+
+// Folders of posts by feed ID when a maildir is split by tag
+func tagFolders(s *state, user database.User) (folders map[int32][]string, err error) {
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get followed feeds: %w", err)
+	}
+
+	folders = map[int32][]string{}
+	for _, follow := range follows {
+		tag := "untagged"
+		if follow.Tag.Valid {
+			tag = follow.Tag.String
+		}
+		folders[follow.FeedID] = append(folders[follow.FeedID], maildirFolderName(tag))
+	}
+
+	return folders, err
+}
+
+// Write posts of followed feeds to an mbox file or a maildir, skipping posts exported before
+func exportMailbox(s *state, cmd command, user database.User) (err error) {
+	kind := cmd.args[0]
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	since := flags.String("since", "", "posts published since a date (2006-01-02) or a period ago (e.g. 7d)")
+	tag := flags.String("tag", "", "export only feeds with this tag")
+	starred := flags.Bool("starred", false, "export only starred posts")
+	limit := flags.Int("limit", defaultMailboxLimit, "maximum number of posts")
+	folders := flags.String("folders", "feed", "maildir folder per feed or tag, or none for a single maildir")
+	args, err := parseFlags(flags, cmd.args[1:])
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if len(args) < 1 {
+		return fmt.Errorf("%s %s has not enough arguments", cmd.name, kind)
+	}
+	if !slices.Contains([]string{"feed", "tag", "none"}, *folders) {
+		return fmt.Errorf("folders must be feed, tag or none, got %q", *folders)
+	}
+	if *limit <= 0 {
+		return fmt.Errorf("invalid limit: %d", *limit)
+	}
+	target := args[0]
+
+	params := database.GetPostsForUserParams{
+		UserID: user.ID,
+		StarredOnly: *starred,
+		Tag: normalizeTag(*tag),
+		SortBy: "published",
+		Limit: int32(*limit),
+	}
+	if *since != "" {
+		sinceTime, err := parseDate(*since)
+		if err != nil {return err}
+		params.Since = sql.NullTime{Time: sinceTime, Valid: true}
+	}
+	posts, err := getExportPosts(s, params)
+	if err != nil {
+		return fmt.Errorf("couldn't get posts: %w", err)
+	}
+
+	// folders of a post, a single unnamed one for mbox files and plain maildirs
+	postFolders := func(post database.GetPostsForUserRow) []string {return []string{""}}
+	var syncPath string
+	var mbox *os.File
+	switch kind {
+	case "mbox":
+		syncPath = target + mailboxSyncSuffix
+		mbox, err = os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("couldn't open %s: %w", target, err)
+		}
+		defer mbox.Close()
+	case "maildir":
+		syncPath = filepath.Join(target, mailboxSyncSuffix)
+		err = os.MkdirAll(target, 0700)
+		if err != nil {return}
+		switch *folders {
+		case "feed":
+			postFolders = func(post database.GetPostsForUserRow) []string {
+				return []string{maildirFolderName(post.FeedName)}
+			}
+		case "tag":
+			byFeed, err := tagFolders(s, user)
+			if err != nil {return err}
+			postFolders = func(post database.GetPostsForUserRow) []string {
+				// starred posts of unfollowed feeds have no tags
+				if folders, ok := byFeed[post.FeedID]; ok {return folders}
+				return []string{maildirFolderName("untagged")}
+			}
+		default:
+			err = makeMaildir(target)
+			if err != nil {return}
+		}
+	}
+
+	sync, err := loadMailboxSync(syncPath)
+	if err != nil {
+		return fmt.Errorf("couldn't read %s: %w", syncPath, err)
+	}
+	// the sync state is saved even if an export fails halfway
+	defer func() {
+		saveErr := sync.save()
+		if err == nil && saveErr != nil {
+			err = fmt.Errorf("couldn't save %s: %w", syncPath, saveErr)
+		}
+	}()
+
+	written := 0
+	// oldest posts go first
+	for _, post := range slices.Backward(posts) {
+		msg := postMessage(post)
+		for _, folder := range postFolders(post) {
+			if sync.has(folder, msg.MessageID) {continue}
+
+			data, err := msg.Bytes()
+			if err != nil {
+				return fmt.Errorf("couldn't encode post %d: %w", post.ID, err)
+			}
+
+			if kind == "mbox" {
+				err = appendMbox(mbox, post.PublishedAt, data)
+			} else {
+				dir := filepath.Join(target, folder)
+				err = makeMaildir(dir)
+				if err == nil {
+					err = deliverMaildir(dir, msg.MessageID, data)
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("couldn't write post %d: %w", post.ID, err)
+			}
+
+			sync.add(folder, msg.MessageID)
+			written++
+		}
+	}
+
+	if mbox != nil {
+		err = mbox.Close()
+		if err != nil {return}
+	}
+
+	fmt.Printf("Exported %d new messages to %s\n", written, target)
+	return err
+}
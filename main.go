@@ -17,6 +17,7 @@ type state struct {
     cfg *config.Config
 	client *requests.Client
 	db *database.Queries
+	options globalOptions
 }
 
 
@@ -33,7 +34,19 @@ func main() {
 	}
 	defer db.Close()
 
-	webClient := requests.NewClient(15 * time.Second)
+	webClient, err := requests.NewClient(requests.Options{
+		Timeout: 15 * time.Second,
+		Allowlist: cfg.FetchAllowlist,
+		ProxyURL: cfg.ProxyURL,
+		TLS: requests.TLSOptions{
+			CAFile: cfg.CAFile,
+			ClientCertFile: cfg.ClientCertFile,
+			ClientKeyFile: cfg.ClientKeyFile,
+		},
+	})
+	if err != nil {
+		log.Fatalf("error creating web client: %v", err)
+	}
 	dbQueries := database.New(db)
 
 	appState := &state{
@@ -53,18 +66,48 @@ func main() {
 	cmds.register("follow", middlewareLoggedIn(handlerFollow))
 	cmds.register("following", middlewareLoggedIn(handlerFollowing))
 	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollow))
+	cmds.register("alias", middlewareLoggedIn(handlerAlias))
+	cmds.register("tag", middlewareLoggedIn(handlerTag))
+	cmds.register("untag", middlewareLoggedIn(handlerUntag))
 	cmds.register("browse", middlewareLoggedIn(handlerBrowsePosts))
+	cmds.register("read", middlewareLoggedIn(handlerRead))
+	cmds.register("open", middlewareLoggedIn(handlerOpen))
+	cmds.register("mark-read", middlewareLoggedIn(handlerMarkRead))
+	cmds.register("filter", middlewareLoggedIn(handlerFilter))
+	cmds.register("search", middlewareLoggedIn(handlerSearch))
+	cmds.register("smartfeed", middlewareLoggedIn(handlerSmartFeed))
+	cmds.register("star", middlewareLoggedIn(handlerStar))
+	cmds.register("unstar", middlewareLoggedIn(handlerUnstar))
+	cmds.register("starred", middlewareLoggedIn(handlerStarred))
+	cmds.register("tui", middlewareLoggedIn(handlerTUI))
+	cmds.register("publish", handlerPublish)
+	cmds.register("planet", handlerPlanet)
+	cmds.register("digest", middlewareLoggedIn(handlerDigest))
+	cmds.register("export", middlewareLoggedIn(handlerExport))
+	cmds.register("serve", handlerServe)
+	cmds.register("autoextract", middlewareLoggedIn(handlerAutoExtract))
+	cmds.register("feedauth", middlewareLoggedIn(handlerFeedAuth))
 	// clearing table command for tests
 	cmds.register("reset", handlerReset)
 
-	if len(os.Args) < 2 {
+	options, args, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	appState.options = options
+	appState.options.timeFormat = defaultTimeFormat
+	if cfg.TimeFormat != "" {
+		appState.options.timeFormat = cfg.TimeFormat
+	}
+
+	if len(args) < 1 {
 		log.Fatal("not enough arguments")
 		return
 	}
 
 	cmd := command{
-		name: os.Args[1],
-		args: os.Args[2:],
+		name: args[0],
+		args: args[1:],
 	}
 	
 	err = cmds.run(appState, cmd)
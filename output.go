@@ -0,0 +1,252 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/DIVIgor/gator/internal/htmltext"
+)
+
+// Machine-readable output formats of the listing commands
+var outputFormats = []string{"json", "csv", "tsv", "table"}
+
+
+// Helpers available in output templates, dates are formatted with the given layout by default
+func templateFuncs(timeFormat string) template.FuncMap {
+	return template.FuncMap{
+		"date": func(value any, layout ...string) (string, error) {
+			return templateDate(timeFormat, value, layout...)
+		},
+		"truncate": templateTruncate,
+		"striphtml": htmltext.Strip,
+	}
+}
+
+
+// The template flag of listing commands
+func addFormatFlag(flags *flag.FlagSet) *string {
+	return flags.String("format", "", "text/template executed for every record, e.g. '{{.title}} {{.url}}'")
+}
+
+// Whether a listing is printed with a template or in a machine-readable format
+// instead of the human-readable text
+func customOutput(s *state, kind, format string) bool {
+	return format != "" || s.options.output != "" || s.cfg.Templates[kind] != ""
+}
+
+// Print a listing with the inline template, the --output format or the config template, in this order
+func writeListing(s *state, kind, format string, list *listing) (err error) {
+	if format == "" && s.options.output != "" {
+		return list.write(os.Stdout, s.options.output)
+	}
+
+	name := "format"
+	if format == "" {
+		name = s.cfg.Templates[kind]
+		src, err := os.ReadFile(expandHome(name))
+		if err != nil {
+			return fmt.Errorf("couldn't read %s template: %w", kind, err)
+		}
+		format = string(src)
+	}
+
+	tmpl, err := template.New(filepath.Base(name)).Funcs(templateFuncs(s.options.timeFormat)).Parse(format)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	return list.writeTemplate(os.Stdout, tmpl)
+}
+
+// Paths in the config may start with ~
+func expandHome(path string) string {
+	rest, found := strings.CutPrefix(path, "~/")
+	if !found {return path}
+
+	home, err := os.UserHomeDir()
+	if err != nil {return path}
+	return filepath.Join(home, rest)
+}
+
+// Records of a listing with their field names in column order
+type listing struct {
+	fields []string
+	records [][]any
+}
+
+func newListing(fields ...string) *listing {
+	return &listing{fields: fields, records: [][]any{}}
+}
+
+// Add a record, values go in the same order as the fields
+func (l *listing) add(values ...any) {
+	l.records = append(l.records, values)
+}
+
+// Write the listing in the given format
+func (l *listing) write(w io.Writer, format string) (err error) {
+	switch format {
+	case "json":
+		return l.writeJSON(w)
+	case "csv":
+		return l.writeDelimited(w, ',')
+	case "tsv":
+		return l.writeDelimited(w, '\t')
+	case "table":
+		return l.writeTable(w)
+	}
+
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// An array of objects keyed by field names
+func (l *listing) writeJSON(w io.Writer) (err error) {
+	objects := make([]map[string]any, 0, len(l.records))
+	for _, record := range l.records {
+		object := make(map[string]any, len(l.fields))
+		for idx, field := range l.fields {
+			object[field] = jsonValue(record[idx])
+		}
+		objects = append(objects, object)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(objects)
+}
+
+// A header row followed by a row per record
+func (l *listing) writeDelimited(w io.Writer, delimiter rune) (err error) {
+	writer := csv.NewWriter(w)
+	writer.Comma = delimiter
+
+	err = writer.Write(l.fields)
+	if err != nil {return}
+	for _, record := range l.records {
+		row := make([]string, len(record))
+		for idx, value := range record {
+			row[idx] = textValue(value)
+		}
+		// tabs and line breaks would break TSV rows
+		if delimiter == '\t' {
+			for idx := range row {
+				row[idx] = strings.Join(strings.Fields(row[idx]), " ")
+			}
+		}
+		err = writer.Write(row)
+		if err != nil {return}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Aligned columns for reading in the terminal
+func (l *listing) writeTable(w io.Writer) (err error) {
+	writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.ToUpper(strings.Join(l.fields, "\t")))
+	for _, record := range l.records {
+		row := make([]string, len(record))
+		for idx, value := range record {
+			row[idx] = strings.Join(strings.Fields(textValue(value)), " ")
+		}
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+
+	return writer.Flush()
+}
+
+// Execute the template for every record, fields are accessed by name, e.g. {{.title}}.
+// Records are put on separate lines unless the template ends with a line break itself.
+func (l *listing) writeTemplate(w io.Writer, tmpl *template.Template) (err error) {
+	for _, record := range l.records {
+		object := make(map[string]any, len(l.fields))
+		for idx, field := range l.fields {
+			object[field] = jsonValue(record[idx])
+		}
+
+		var out strings.Builder
+		err = tmpl.Execute(&out, object)
+		if err != nil {return}
+		text := out.String()
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		_, err = io.WriteString(w, text)
+		if err != nil {return}
+	}
+
+	return err
+}
+
+// Format a time with the configured layout or the given one, nulls are empty
+func templateDate(timeFormat string, value any, layout ...string) (string, error) {
+	if len(layout) > 1 {
+		return "", errors.New("date takes at most one layout")
+	}
+	format := timeFormat
+	if len(layout) == 1 {
+		format = layout[0]
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case time.Time:
+		return v.Format(format), nil
+	}
+	return "", fmt.Errorf("date expects a time, got %T", value)
+}
+
+// Cut text to a number of characters adding an ellipsis, nulls are empty
+func templateTruncate(length int, value any) string {
+	text := textValue(value)
+	runes := []rune(text)
+	if length < 1 || len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-1]) + "…"
+}
+
+// Nullable database values become JSON nulls
+func jsonValue(value any) any {
+	switch v := value.(type) {
+	case sql.NullString:
+		if !v.Valid {return nil}
+		return v.String
+	case sql.NullTime:
+		if !v.Valid {return nil}
+		return v.Time
+	case sql.NullInt32:
+		if !v.Valid {return nil}
+		return v.Int32
+	}
+
+	return value
+}
+
+// Values as text, nulls are empty
+func textValue(value any) string {
+	switch v := jsonValue(value).(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Pager used when $PAGER isn't set
+const defaultPager string = "less"
+
+
+// Whether a file is a terminal rather than a pipe or a regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Send the standard output through $PAGER when it goes to a terminal.
+// Returns a function that restores the output and waits for the pager to quit.
+func startPager(s *state) (stop func()) {
+	stop = func() {}
+	if s.options.noPager || !isTerminal(os.Stdout) {return}
+
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{defaultPager}
+	}
+
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	// less quits when the output fits the screen and keeps colors
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {return}
+	cmd.Stdin = reader
+	err = cmd.Start()
+	reader.Close()
+	if err != nil {
+		writer.Close()
+		return
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	return func() {
+		os.Stdout = stdout
+		writer.Close()
+		cmd.Wait()
+	}
+}
+
+// Whether a URL is an absolute http(s) one
+func isWebURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// Open a URL in $BROWSER or the desktop's default browser
+func openBrowser(rawURL string) (err error) {
+	// only web links are handed over, other schemes could run local handlers
+	if !isWebURL(rawURL) {
+		return fmt.Errorf("refusing to open %q: not an http(s) URL", rawURL)
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {return}
+	link := parsed.String()
+
+	var cmd *exec.Cmd
+	// $BROWSER may list several browsers separated by colons, %s is replaced with the URL
+	if browsers := os.Getenv("BROWSER"); browsers != "" {
+		for _, browser := range strings.Split(browsers, ":") {
+			args := strings.Fields(browser)
+			if len(args) == 0 {continue}
+			if _, err := exec.LookPath(args[0]); err != nil {continue}
+
+			replaced := false
+			for idx, arg := range args {
+				if strings.Contains(arg, "%s") {
+					args[idx] = strings.ReplaceAll(arg, "%s", link)
+					replaced = true
+				}
+			}
+			if !replaced {
+				args = append(args, link)
+			}
+			cmd = exec.Command(args[0], args[1:]...)
+			break
+		}
+	}
+
+	if cmd == nil {
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("open", link)
+		case "windows":
+			cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+		default:
+			cmd = exec.Command("xdg-open", link)
+		}
+	}
+
+	err = cmd.Start()
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("no browser found, set $BROWSER: %w", err)
+	}
+	if err != nil {return}
+
+	// the browser keeps running on its own
+	return cmd.Process.Release()
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DIVIgor/gator/internal/database"
+	"github.com/DIVIgor/gator/internal/htmltext"
+)
+
+//go:embed templates/planet
+var planetFiles embed.FS
+
+const defaultPlanetLimit int = 50
+
+// Files copied to the site as they are
+var planetAssets = []string{"style.css"}
+
+
+// Data of a planet page, feed pages have Feed set
+type planetPage struct {
+	Title string
+	Generated time.Time
+	// relative path from the page to the site root
+	Root string
+	Feeds []planetFeed
+	Feed *planetFeed
+	Posts []planetPost
+}
+
+type planetFeed struct {
+	Name string
+	URL string
+	Page string
+}
+
+type planetPost struct {
+	Title string
+	URL string
+	FeedName string
+	FeedPage string
+	Published time.Time
+	Content template.HTML
+	Root string
+}
+
+
+// Manage the static planet site
+func handlerPlanet(s *state, cmd command) (err error) {
+	if len(cmd.args) < 1 || cmd.args[0] != "build" {
+		return fmt.Errorf("usage: %s build <output directory> [flags]", cmd.name)
+	}
+
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	userName := flags.String("user", s.cfg.User, "user whose followed feeds are rendered")
+	tag := flags.String("tag", "", "render only feeds with this tag")
+	title := flags.String("title", "Planet", "site title")
+	limit := flags.Int("limit", defaultPlanetLimit, "number of posts on the index and on each feed page")
+	templateDir := flags.String("templates", "", "directory with templates and assets overriding the embedded ones")
+	baseURL := flags.String("base-url", "", "URL the site is published at, used in the aggregated feed")
+	args, err := parseFlags(flags, cmd.args[1:])
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if len(args) < 1 {
+		return fmt.Errorf("%s build has not enough arguments", cmd.name)
+	}
+	if *limit <= 0 || *limit > maxPublishLimit {
+		return fmt.Errorf("limit must be between 1 and %d", maxPublishLimit)
+	}
+	outDir := args[0]
+
+	user, err := s.db.GetUser(context.Background(), *userName)
+	if err != nil {
+		return fmt.Errorf("couldn't get user %q: %w", *userName, err)
+	}
+
+	templates, err := loadPlanetTemplates(*templateDir, s.options.timeFormat)
+	if err != nil {return}
+
+	err = os.MkdirAll(filepath.Join(outDir, "feeds"), 0755)
+	if err != nil {
+		return fmt.Errorf("couldn't create %s: %w", outDir, err)
+	}
+
+	feeds, pages, err := planetFeeds(s, user, normalizeTag(*tag))
+	if err != nil {return}
+
+	site := planetPage{
+		Title: *title,
+		Generated: time.Now(),
+		Feeds: feeds,
+	}
+
+	// the index and the aggregated feed show the river of all the feeds
+	r, err := loadRiver(s, user, normalizeTag(*tag), *limit)
+	if err != nil {return}
+	index := site
+	index.Posts = planetPosts(r.posts, pages, "")
+	err = writePlanetPage(templates, "index.html", filepath.Join(outDir, "index.html"), index)
+	if err != nil {return}
+
+	// feed pages leave muted posts out like the river does
+	filters, err := loadFilters(s, user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't load filter rules: %w", err)
+	}
+	for _, feed := range feeds {
+		fetch := func(limit, offset int32) ([]database.GetPostsForUserRow, error) {
+			return s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
+				UserID: user.ID,
+				Feed: feed.URL,
+				SortBy: "published",
+				Limit: limit,
+				Offset: offset,
+			})
+		}
+		posts, _, err := getFilteredPosts(fetch, int32(*limit), 0, filters)
+		if err != nil {
+			return fmt.Errorf("couldn't get posts of %s: %w", feed.URL, err)
+		}
+
+		page := site
+		page.Root = "../"
+		page.Feed = &feed
+		page.Posts = planetPosts(posts, pages, page.Root)
+		err = writePlanetPage(templates, "feed.html", filepath.Join(outDir, feed.Page), page)
+		if err != nil {return err}
+	}
+
+	r.title = *title
+	if *baseURL != "" {
+		r.selfURL = strings.TrimSuffix(*baseURL, "/") + "/atom.xml"
+	}
+	doc, err := r.render("atom", *baseURL)
+	if err != nil {return}
+	err = os.WriteFile(filepath.Join(outDir, "atom.xml"), doc, 0644)
+	if err != nil {return}
+
+	for _, asset := range planetAssets {
+		err = copyPlanetAsset(*templateDir, asset, filepath.Join(outDir, asset))
+		if err != nil {return}
+	}
+
+	fmt.Printf("Built a planet of %d feeds in %s\n", len(feeds), outDir)
+	return err
+}
+
+// Followed feeds with their page paths, and the page paths by feed ID
+func planetFeeds(s *state, user database.User, tag string) (feeds []planetFeed, pages map[int32]string, err error) {
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't get followed feeds: %w", err)
+	}
+
+	pages = map[int32]string{}
+	// rows are repeated for every tag of a feed
+	for _, follow := range follows {
+		if tag != "" && follow.Tag.String != tag {continue}
+
+		if _, seen := pages[follow.FeedID]; seen {continue}
+
+		pages[follow.FeedID] = fmt.Sprintf("feeds/%d.html", follow.FeedID)
+		feeds = append(feeds, planetFeed{Name: follow.FeedName, URL: follow.FeedUrl, Page: pages[follow.FeedID]})
+	}
+
+	return feeds, pages, err
+}
+
+func planetPosts(rows []database.GetPostsForUserRow, pages map[int32]string, root string) (posts []planetPost) {
+	for _, row := range rows {
+		posts = append(posts, planetPost{
+			Title: row.Title,
+			URL: row.Url,
+			FeedName: row.FeedName,
+			FeedPage: pages[row.FeedID],
+			Published: row.PublishedAt,
+			// posts saved by older versions weren't sanitized on ingest
+			Content: template.HTML(htmltext.Sanitize(postHTML(row))),
+			Root: root,
+		})
+	}
+	return posts
+}
+
+// Parse the embedded templates, files in the override directory replace the ones with the same name
+func loadPlanetTemplates(overrideDir, timeFormat string) (templates *template.Template, err error) {
+	embedded, err := fs.Sub(planetFiles, "templates/planet")
+	if err != nil {return}
+
+	templates, err = template.New("planet").Funcs(template.FuncMap(templateFuncs(timeFormat))).ParseFS(embedded, "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse embedded templates: %w", err)
+	}
+	if overrideDir == "" {
+		return templates, err
+	}
+
+	overrides, err := filepath.Glob(filepath.Join(overrideDir, "*.html"))
+	if err != nil || len(overrides) == 0 {
+		return templates, err
+	}
+	templates, err = templates.ParseFiles(overrides...)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse templates in %s: %w", overrideDir, err)
+	}
+
+	return templates, err
+}
+
+func writePlanetPage(templates *template.Template, name, path string, page planetPage) (err error) {
+	file, err := os.Create(path)
+	if err != nil {return}
+	defer file.Close()
+
+	err = templates.ExecuteTemplate(file, name, page)
+	if err != nil {
+		return fmt.Errorf("couldn't render %s: %w", path, err)
+	}
+
+	return file.Close()
+}
+
+func copyPlanetAsset(overrideDir, name, path string) (err error) {
+	data, err := planetFiles.ReadFile("templates/planet/" + name)
+	if overrideDir != "" {
+		override, overrideErr := os.ReadFile(filepath.Join(overrideDir, name))
+		if !os.IsNotExist(overrideErr) {
+			data, err = override, overrideErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't read %s: %w", name, err)
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
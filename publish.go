@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/DIVIgor/gator/internal/database"
+	"github.com/DIVIgor/gator/internal/htmltext"
+)
+
+// Formats of published feeds and their content types
+var publishFormats = map[string]string{
+	"atom": "application/atom+xml; charset=utf-8",
+	"rss": "application/rss+xml; charset=utf-8",
+	"json": "application/feed+json; charset=utf-8",
+}
+
+const defaultPublishLimit int = 50
+const maxPublishLimit int = 500
+
+
+// A river of posts of the feeds a user follows
+type river struct {
+	title string
+	id string
+	// URL the river is served at, empty if it isn't served
+	selfURL string
+	posts []database.GetPostsForUserRow
+}
+
+type atomFeed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	Title string `xml:"title"`
+	ID string `xml:"id"`
+	Updated string `xml:"updated"`
+	Links []atomOutLink `xml:"link"`
+	Author atomPerson `xml:"author"`
+	Generator string `xml:"generator"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomOutLink struct {
+	Rel string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name" json:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	ID string `xml:"id"`
+	Updated string `xml:"updated"`
+	Published string `xml:"published"`
+	Link atomOutLink `xml:"link"`
+	Author atomPerson `xml:"author"`
+	Content *atomText `xml:"content,omitempty"`
+}
+
+type rssOutFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string `xml:"version,attr"`
+	AtomNS string `xml:"xmlns:atom,attr"`
+	Channel rssOutChannel `xml:"channel"`
+}
+
+type rssOutChannel struct {
+	Title string `xml:"title"`
+	Link string `xml:"link"`
+	Description string `xml:"description"`
+	LastBuildDate string `xml:"lastBuildDate"`
+	Generator string `xml:"generator"`
+	SelfLink *atomOutLink `xml:"atom:link,omitempty"`
+	Items []rssOutItem `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool `xml:"isPermaLink,attr"`
+	Value string `xml:",chardata"`
+}
+
+type rssOutItem struct {
+	Title string `xml:"title"`
+	Link string `xml:"link"`
+	GUID rssGUID `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+	Category string `xml:"category"`
+	Description string `xml:"description,omitempty"`
+}
+
+// JSON Feed 1.1, https://www.jsonfeed.org/version/1.1/
+type jsonFeed struct {
+	Version string `json:"version"`
+	Title string `json:"title"`
+	HomePageURL string `json:"home_page_url,omitempty"`
+	FeedURL string `json:"feed_url,omitempty"`
+	Items []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID string `json:"id"`
+	URL string `json:"url"`
+	Title string `json:"title"`
+	ContentHTML string `json:"content_html,omitempty"`
+	DatePublished string `json:"date_published"`
+	Authors []atomPerson `json:"authors"`
+}
+
+
+// Get the latest posts of the feeds a user follows, muted posts are left out
+func loadRiver(s *state, user database.User, tag string, limit int) (r river, err error) {
+	filters, err := loadFilters(s, user.ID)
+	if err != nil {
+		return r, fmt.Errorf("couldn't load filter rules: %w", err)
+	}
+
+	fetch := func(limit, offset int32) ([]database.GetPostsForUserRow, error) {
+		return s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
+			UserID: user.ID,
+			Tag: tag,
+			SortBy: "published",
+			Limit: limit,
+			Offset: offset,
+		})
+	}
+	r.posts, _, err = getFilteredPosts(fetch, int32(limit), 0, filters)
+	if err != nil {
+		return r, fmt.Errorf("couldn't get posts: %w", err)
+	}
+
+	r.title = fmt.Sprintf("%s's river", user.Name)
+	r.id = "urn:gator:river:" + url.PathEscape(user.Name)
+	if tag != "" {
+		r.title += " - " + tag
+		r.id += ":" + url.PathEscape(tag)
+	}
+
+	return r, err
+}
+
+// URL a river is served at by the HTTP listener
+func riverURL(publicURL, userName, tag, format string) string {
+	if publicURL == "" {return ""}
+
+	query := url.Values{"format": {format}}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+	return fmt.Sprintf("%s/publish/%s?%s", publicURL, url.PathEscape(userName), query.Encode())
+}
+
+// Time of the newest post or now for an empty river
+func (r river) updated() time.Time {
+	if len(r.posts) == 0 {
+		return time.Now().UTC()
+	}
+	return r.posts[0].PublishedAt.UTC()
+}
+
+// The website of the river, feeds need one even if the river isn't served
+func (r river) homeURL(publicURL string) string {
+	if publicURL != "" {return publicURL}
+	if len(r.posts) > 0 {return r.posts[0].Url}
+	return "urn:gator"
+}
+
+// Full articles are published where they were extracted
+func postHTML(post database.GetPostsForUserRow) string {
+	if post.Content.Valid {
+		return post.Content.String
+	}
+	return post.Description.String
+}
+
+// Render a river as a feed document
+func (r river) render(format, publicURL string) (doc []byte, err error) {
+	var out any
+	switch format {
+	case "atom":
+		feed := atomFeed{
+			Title: r.title,
+			ID: r.id,
+			Updated: r.updated().Format(time.RFC3339),
+			Links: []atomOutLink{{Rel: "alternate", Href: r.homeURL(publicURL)}},
+			Author: atomPerson{Name: "gator"},
+			Generator: "gator",
+		}
+		if r.selfURL != "" {
+			feed.Links = append(feed.Links, atomOutLink{Rel: "self", Type: "application/atom+xml", Href: r.selfURL})
+		}
+		for _, post := range r.posts {
+			entry := atomEntry{
+				Title: post.Title,
+				ID: post.Url,
+				Updated: post.PublishedAt.UTC().Format(time.RFC3339),
+				Published: post.PublishedAt.UTC().Format(time.RFC3339),
+				Link: atomOutLink{Rel: "alternate", Href: post.Url},
+				Author: atomPerson{Name: post.FeedName},
+			}
+			if content := htmltext.Sanitize(postHTML(post)); content != "" {
+				entry.Content = &atomText{Type: "html", Text: content}
+			}
+			feed.Entries = append(feed.Entries, entry)
+		}
+		out = feed
+	case "rss":
+		feed := rssOutFeed{
+			Version: "2.0",
+			AtomNS: "http://www.w3.org/2005/Atom",
+			Channel: rssOutChannel{
+				Title: r.title,
+				Link: r.homeURL(publicURL),
+				Description: "Posts of the feeds followed on gator",
+				LastBuildDate: r.updated().Format(time.RFC1123Z),
+				Generator: "gator",
+			},
+		}
+		if r.selfURL != "" {
+			feed.Channel.SelfLink = &atomOutLink{Rel: "self", Type: "application/rss+xml", Href: r.selfURL}
+		}
+		for _, post := range r.posts {
+			feed.Channel.Items = append(feed.Channel.Items, rssOutItem{
+				Title: post.Title,
+				Link: post.Url,
+				GUID: rssGUID{IsPermaLink: true, Value: post.Url},
+				PubDate: post.PublishedAt.Format(time.RFC1123Z),
+				Category: post.FeedName,
+				Description: htmltext.Sanitize(postHTML(post)),
+			})
+		}
+		out = feed
+	case "json":
+		feed := jsonFeed{
+			Version: "https://jsonfeed.org/version/1.1",
+			Title: r.title,
+			HomePageURL: publicURL,
+			FeedURL: r.selfURL,
+			Items: []jsonFeedItem{},
+		}
+		for _, post := range r.posts {
+			feed.Items = append(feed.Items, jsonFeedItem{
+				ID: post.Url,
+				URL: post.Url,
+				Title: post.Title,
+				ContentHTML: htmltext.Sanitize(postHTML(post)),
+				DatePublished: post.PublishedAt.UTC().Format(time.RFC3339),
+				Authors: []atomPerson{{Name: post.FeedName}},
+			})
+		}
+		return json.MarshalIndent(feed, "", "  ")
+	default:
+		return nil, fmt.Errorf("format must be atom, rss or json, got %q", format)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(out)
+	if err != nil {return}
+	buf.WriteString("\n")
+
+	return buf.Bytes(), err
+}
+
+// Render the posts of a user as a feed document
+func handlerPublish(s *state, cmd command) (err error) {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	userName := flags.String("user", s.cfg.User, "user whose followed feeds are published")
+	tag := flags.String("tag", "", "publish only feeds with this tag")
+	format := flags.String("format", "atom", "feed format: atom, rss or json")
+	outFile := flags.String("out", "", "write the feed to a file instead of stdout")
+	limit := flags.Int("limit", defaultPublishLimit, "number of posts")
+	_, err = parseFlags(flags, cmd.args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if _, ok := publishFormats[*format]; !ok {
+		return fmt.Errorf("format must be atom, rss or json, got %q", *format)
+	}
+	if *limit <= 0 || *limit > maxPublishLimit {
+		return fmt.Errorf("limit must be between 1 and %d", maxPublishLimit)
+	}
+
+	user, err := s.db.GetUser(context.Background(), *userName)
+	if err != nil {
+		return fmt.Errorf("couldn't get user %q: %w", *userName, err)
+	}
+
+	r, err := loadRiver(s, user, normalizeTag(*tag), *limit)
+	if err != nil {return}
+	r.selfURL = riverURL(s.cfg.PublicURL, user.Name, normalizeTag(*tag), *format)
+
+	doc, err := r.render(*format, s.cfg.PublicURL)
+	if err != nil {return}
+
+	if *outFile == "" {
+		_, err = os.Stdout.Write(doc)
+		return err
+	}
+
+	err = os.WriteFile(*outFile, doc, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't write %s: %w", *outFile, err)
+	}
+	fmt.Printf("Published %d posts to %s\n", len(r.posts), *outFile)
+
+	return err
+}
+
+// Serve the river of a user who opted in to publishing, the format, tag and limit are taken from the query
+func handlerPublishServe(s *state, w http.ResponseWriter, r *http.Request) {
+	if !slices.Contains(s.cfg.PublishUsers, r.PathValue("user")) {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "atom"
+	}
+	contentType, ok := publishFormats[format]
+	if !ok {
+		http.Error(w, "unknown format", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultPublishLimit
+	if query.Has("limit") {
+		var err error
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || limit <= 0 || limit > maxPublishLimit {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	user, err := s.db.GetUser(r.Context(), r.PathValue("user"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	tag := normalizeTag(query.Get("tag"))
+	river, err := loadRiver(s, user, tag, limit)
+	if err != nil {
+		log.Printf("Couldn't publish posts of %s: %v", user.Name, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	river.selfURL = riverURL(s.cfg.PublicURL, user.Name, tag, format)
+
+	doc, err := river.render(format, s.cfg.PublicURL)
+	if err != nil {
+		log.Printf("Couldn't render posts of %s: %v", user.Name, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(doc)
+}
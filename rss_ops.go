@@ -1,18 +1,107 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"html"
 	"io"
+	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/DIVIgor/gator/internal/config"
 	"github.com/DIVIgor/gator/internal/requests"
 )
 
+// Limits applied to remote feed documents
+const (
+	maxFeedSize int64 = 10 << 20
+	maxXMLDepth int = 32
+	maxFeedItems int = 1000
+	maxFieldLength int = 256 << 10
+)
+
+const atomNamespace string = "http://www.w3.org/2005/Atom"
+
+var errUnsafeFeed = errors.New("unsafe feed")
+
+
+// Walk through the document tokens and reject feeds that exceed parser limits
+// before unmarshalling them
+func checkXML(rawData []byte) (err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(rawData))
+	depth, items, fieldLength := 0, 0, 0
 
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {return nil}
+		if err != nil {return err}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			fieldLength = 0
+			if depth > maxXMLDepth {
+				return fmt.Errorf("%w: nesting deeper than %d elements", errUnsafeFeed, maxXMLDepth)
+			}
+			if t.Name.Local == "item" || t.Name.Local == "entry" {
+				items++
+			}
+			if items > maxFeedItems {
+				return fmt.Errorf("%w: more than %d items", errUnsafeFeed, maxFeedItems)
+			}
+		case xml.EndElement:
+			depth--
+			fieldLength = 0
+		case xml.CharData:
+			fieldLength += len(t)
+			if fieldLength > maxFieldLength {
+				return fmt.Errorf("%w: field longer than %d bytes", errUnsafeFeed, maxFieldLength)
+			}
+		case xml.Directive:
+			directive := strings.ToUpper(string(t))
+			if strings.Contains(directive, "<!ENTITY") || strings.Contains(directive, "ENTITY ") ||
+				(strings.HasPrefix(directive, "DOCTYPE") && strings.Contains(directive, "[")) {
+				return fmt.Errorf("%w: DOCTYPE entity declarations are not allowed", errUnsafeFeed)
+			}
+		}
+	}
+}
+
+// Name of the root element of a document
+func xmlRoot(rawData []byte) (name xml.Name, err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(rawData))
+	for {
+		token, err := decoder.Token()
+		if err != nil {return name, err}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name, err
+		}
+	}
+}
+
+// Parse an RSS or Atom document, other formats are rejected
 func parseXML(rawData []byte) (feed *RSSFeed, err error) {
-	err = xml.Unmarshal(rawData, &feed)
+	err = checkXML(rawData)
+	if err != nil {return feed, err}
+
+	root, err := xmlRoot(rawData)
+	if err != nil {return feed, err}
+
+	switch {
+	case root.Local == "rss":
+		err = xml.Unmarshal(rawData, &feed)
+	case root.Local == "feed" && root.Space == atomNamespace:
+		// Atom text constructs say how they're encoded, the RSS unescaping below doesn't apply
+		var atom AtomFeed
+		err = xml.Unmarshal(rawData, &atom)
+		return atom.rss(), err
+	default:
+		err = fmt.Errorf("unsupported feed format: <%s>", root.Local)
+	}
 	if err != nil {return feed, err}
 
 	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
@@ -26,17 +115,96 @@ func parseXML(rawData []byte) (feed *RSSFeed, err error) {
 	return feed, err
 }
 
-func fetchFeed(client *requests.Client, ctx context.Context, feedURL string) (feed *RSSFeed, err error) {
+// Link with the given relation, alternate links may leave it out
+func atomLink(links []AtomLink, rel string) string {
+	for _, link := range links {
+		if link.Rel == rel || (rel == "alternate" && link.Rel == "") {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// Text construct as HTML
+func (t AtomText) html() string {
+	switch t.Type {
+	case "xhtml":
+		return strings.TrimSpace(t.Inner)
+	case "html":
+		return t.Text
+	default:
+		return html.EscapeString(t.Text)
+	}
+}
+
+func (a AtomFeed) rss() (feed *RSSFeed) {
+	feed = &RSSFeed{}
+	feed.Channel.Title = a.Title
+	feed.Channel.Description = a.Subtitle
+	feed.Channel.Link = atomLink(a.Links, "alternate")
+	feed.Channel.AtomLinks = a.Links
+
+	for _, entry := range a.Entries {
+		description := entry.Content
+		if description.Text == "" && description.Inner == "" {
+			description = entry.Summary
+		}
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title: entry.Title.Text,
+			Link: atomLink(entry.Links, "alternate"),
+			Description: description.html(),
+			PubDate: published,
+			GUID: entry.ID,
+		})
+	}
+	return feed
+}
+
+// Convert the configured request settings of a feed for the web client
+func feedRequestSettings(cfg *config.Config, feedURL string) (settings requests.Settings) {
+	feedRequest, exists := cfg.FeedRequests[feedURL]
+	if !exists {return}
+
+	settings.Header = http.Header{}
+	for name, value := range feedRequest.Headers {
+		settings.Header.Set(name, value)
+	}
+	settings.Username = feedRequest.BasicUser
+	settings.Password = feedRequest.BasicPassword
+	if feedRequest.TokenParam != "" {
+		settings.Query = url.Values{feedRequest.TokenParam: {feedRequest.Token}}
+	}
+	settings.TLS = requests.TLSOptions{
+		CAFile: feedRequest.CAFile,
+		ClientCertFile: feedRequest.ClientCertFile,
+		ClientKeyFile: feedRequest.ClientKeyFile,
+		InsecureSkipVerify: feedRequest.InsecureSkipVerify,
+	}
+
+	return settings
+}
+
+func fetchFeed(client *requests.Client, ctx context.Context, feedURL string, settings requests.Settings) (feed *RSSFeed, err error) {
 	if len(feedURL) == 0 {
 		err = errors.New("no URL provided")
 		return
 	}
 
-	resp, err := client.MakeRequest(ctx, "GET", feedURL, nil)
+	resp, err := client.MakeRequestWith(ctx, "GET", feedURL, nil, settings)
 	if err != nil {return}
+	defer resp.Body.Close()
 
-	respData, err := io.ReadAll(resp.Body)
+	// read one byte past the limit to detect oversized documents
+	respData, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
 	if err != nil {return}
+	if int64(len(respData)) > maxFeedSize {
+		return feed, fmt.Errorf("%w: document larger than %d bytes", errUnsafeFeed, maxFeedSize)
+	}
 
 	return parseXML(respData)
-}
\ No newline at end of file
+}
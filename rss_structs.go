@@ -4,6 +4,8 @@ package main
 type RSSFeed struct {
 	Channel struct {
 		Title       string    `xml:"title"`
+		// must precede Link, otherwise atom:link elements end up in Link
+		AtomLinks   []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
 		Link        string    `xml:"link"`
 		Description string    `xml:"description"`
 		Item        []RSSItem `xml:"item"`
@@ -15,4 +17,36 @@ type RSSItem struct {
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
-}
\ No newline at end of file
+	GUID        string `xml:"guid"`
+}
+
+// Link to the feed itself or to a WebSub hub
+type AtomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+// Atom documents are converted to RSSFeed after parsing
+type AtomFeed struct {
+	Title    string      `xml:"http://www.w3.org/2005/Atom title"`
+	Subtitle string      `xml:"http://www.w3.org/2005/Atom subtitle"`
+	Links    []AtomLink  `xml:"http://www.w3.org/2005/Atom link"`
+	Entries  []AtomEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type AtomEntry struct {
+	ID        string     `xml:"http://www.w3.org/2005/Atom id"`
+	Title     AtomText   `xml:"http://www.w3.org/2005/Atom title"`
+	Links     []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+	Summary   AtomText   `xml:"http://www.w3.org/2005/Atom summary"`
+	Content   AtomText   `xml:"http://www.w3.org/2005/Atom content"`
+	Published string     `xml:"http://www.w3.org/2005/Atom published"`
+	Updated   string     `xml:"http://www.w3.org/2005/Atom updated"`
+}
+
+// Text construct: plain text, escaped HTML or inline XHTML
+type AtomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/DIVIgor/gator/internal/database"
+	"github.com/DIVIgor/gator/internal/htmltext"
+)
+
+// Markers of matched words in search snippets and how they are shown in the terminal
+const snippetStartSel, snippetStopSel string = "<<", ">>"
+const highlightStart, highlightStop string = "\033[1;33m", "\033[0m"
+
+
+// Search filters shared by the search command and smart feeds
+type searchFlags struct {
+	feedURL *string
+	tag *string
+	since *string
+	until *string
+}
+
+func addSearchFlags(flags *flag.FlagSet) searchFlags {
+	return searchFlags{
+		feedURL: flags.String("feed", "", "search only the feed with this URL"),
+		tag: flags.String("tag", "", "search only feeds with this tag"),
+		since: flags.String("since", "", "posts published since a date (2006-01-02) or a period ago (e.g. 7d)"),
+		until: flags.String("until", "", "posts published before a date (2006-01-02) or a period ago (e.g. 7d)"),
+	}
+}
+
+// Build search query parameters from the search flags
+func (f searchFlags) params(user database.User, query string, limit int) (params database.SearchPostsForUserParams, err error) {
+	params = database.SearchPostsForUserParams{
+		Query: query,
+		UserID: user.ID,
+		FeedUrl: *f.feedURL,
+		Tag: normalizeTag(*f.tag),
+		Limit: int32(limit),
+	}
+
+	if *f.since != "" {
+		since, err := parseDate(*f.since)
+		if err != nil {return params, err}
+		params.Since = sql.NullTime{Time: since, Valid: true}
+	}
+	if *f.until != "" {
+		until, err := parseDate(*f.until)
+		if err != nil {return params, err}
+		params.Until = sql.NullTime{Time: until, Valid: true}
+	}
+
+	return params, err
+}
+
+// Plain text of a snippet with matched words put between start and stop.
+// The parts are stripped one by one so that the markers aren't taken for markup.
+func snippetText(snippet, start, stop string) string {
+	var sb strings.Builder
+	for idx, part := range strings.Split(snippet, snippetStartSel) {
+		match, rest, found := strings.Cut(part, snippetStopSel)
+		if idx == 0 || !found {
+			sb.WriteString(stripSnippetPart(part))
+			continue
+		}
+		sb.WriteString(start + htmltext.Strip(match) + stop)
+		sb.WriteString(stripSnippetPart(rest))
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// Strip a part of a snippet keeping the spaces around it
+func stripSnippetPart(text string) string {
+	stripped := htmltext.Strip(text)
+	if strings.TrimLeftFunc(text, unicode.IsSpace) != text {
+		stripped = " " + stripped
+	}
+	if strings.TrimRightFunc(text, unicode.IsSpace) != text && stripped != " " {
+		stripped += " "
+	}
+	return stripped
+}
+
+func printSearchResults(s *state, posts []database.SearchPostsForUserRow) {
+	if len(posts) == 0 {
+		fmt.Println("No posts found")
+		return
+	}
+
+	// escape codes would end up in files and pipes
+	start, stop := "", ""
+	if isTerminal(os.Stdout) {
+		start, stop = highlightStart, highlightStop
+	}
+	for _, post := range posts {
+		fmt.Println("ID:", post.ID, "|", "Title:", post.Title, "|", "Feed:", post.FeedName)
+		fmt.Println("Published at:", post.PublishedAt.Format(s.options.timeFormat), "|", fmt.Sprintf("Rank: %.3f", post.Rank))
+		fmt.Println("URL:", post.Url)
+		if snippet := snippetText(post.Snippet, start, stop); snippet != "" {
+			fmt.Println("..." + snippet + "...")
+		}
+		fmt.Println(printDelimiter)
+	}
+}
+
+// Full-text search over the posts of followed feeds ranked by relevance
+func handlerSearch(s *state, cmd command, user database.User) (err error) {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	filters := addSearchFlags(flags)
+	limit := flags.Int("limit", 10, "maximum number of results")
+	format := addFormatFlag(flags)
+	args, err := parseFlags(flags, cmd.args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if len(args) < 1 {
+		return fmt.Errorf("%s has not enough arguments", cmd.name)
+	}
+
+	params, err := filters.params(user, strings.Join(args, " "), *limit)
+	if err != nil {return}
+
+	posts, err := s.db.SearchPostsForUser(context.Background(), params)
+	if err != nil {
+		return fmt.Errorf("couldn't search posts: %w", err)
+	}
+
+	if customOutput(s, "search", *format) {
+		results := newListing("id", "title", "url", "feed", "published_at", "rank", "snippet")
+		for _, post := range posts {
+			results.add(post.ID, post.Title, post.Url, post.FeedName, post.PublishedAt, post.Rank, snippetText(post.Snippet, "", ""))
+		}
+		return writeListing(s, "search", *format, results)
+	}
+
+	printSearchResults(s, posts)
+
+	return err
+}
+
+// Get pages of posts matching a smart feed query, the other browsing parameters apply as well
+func smartFeedPager(s *state, smartFeed database.SmartFeed, params database.GetPostsForUserParams) postPager {
+	return func(limit, offset int32) (posts []database.GetPostsForUserRow, err error) {
+		rows, err := s.db.GetSmartFeedPosts(context.Background(), database.GetSmartFeedPostsParams{
+			UserID: params.UserID,
+			Query: smartFeed.Query,
+			UnreadOnly: params.UnreadOnly,
+			Tag: params.Tag,
+			Since: params.Since,
+			Until: params.Until,
+			After: params.After,
+			SortBy: params.SortBy,
+			Limit: limit,
+			Offset: offset,
+		})
+		if err != nil {return}
+
+		for _, row := range rows {
+			posts = append(posts, database.GetPostsForUserRow{
+				ID: row.ID,
+				Title: row.Title,
+				Url: row.Url,
+				Description: row.Description,
+				PublishedAt: row.PublishedAt,
+				FeedID: row.FeedID,
+				CreatedAt: row.CreatedAt,
+				UpdatedAt: row.UpdatedAt,
+				Content: row.Content,
+				ReadAt: row.ReadAt,
+				FeedName: row.FeedName,
+				Guid: row.Guid,
+			})
+		}
+		return posts, err
+	}
+}
+
+// Manage saved searches: smartfeed add|rm
+func handlerSmartFeed(s *state, cmd command, user database.User) (err error) {
+	if len(cmd.args) < 2 {
+		return fmt.Errorf("%s has not enough arguments", cmd.name)
+	}
+
+	switch cmd.args[0] {
+	case "add":
+		if len(cmd.args) < 3 {
+			return fmt.Errorf("%s add has not enough arguments", cmd.name)
+		}
+
+		smartFeed, err := s.db.CreateSmartFeed(context.Background(), database.CreateSmartFeedParams{
+			UserID: user.ID,
+			Name: cmd.args[1],
+			Query: strings.Join(cmd.args[2:], " "),
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't create smart feed: %w", err)
+		}
+
+		fmt.Printf("Smart feed %q created for search: %s\n", smartFeed.Name, smartFeed.Query)
+	case "rm":
+		removed, err := s.db.DeleteSmartFeed(context.Background(), database.DeleteSmartFeedParams{
+			UserID: user.ID,
+			Name: cmd.args[1],
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't remove smart feed: %w", err)
+		}
+		if removed == 0 {
+			return fmt.Errorf("smart feed %q not found", cmd.args[1])
+		}
+
+		fmt.Printf("Smart feed %q removed\n", cmd.args[1])
+	default:
+		return fmt.Errorf("unknown %s subcommand: %s", cmd.name, cmd.args[0])
+	}
+
+	return err
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http"
+)
+
+
+// HTTP routes served by gator
+func newServerMux(s *state) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /websub/{token}", func(w http.ResponseWriter, r *http.Request) {
+		handlerWebSubVerify(s, w, r)
+	})
+	mux.HandleFunc("POST /websub/{token}", func(w http.ResponseWriter, r *http.Request) {
+		handlerWebSubDeliver(s, w, r)
+	})
+	// rivers are served without authentication
+	mux.HandleFunc("GET /publish/{user}", func(w http.ResponseWriter, r *http.Request) {
+		handlerPublishServe(s, w, r)
+	})
+
+	return mux
+}
@@ -0,0 +1,44 @@
+-- name: UpsertDigestSchedule :one
+INSERT INTO digest_schedules(user_id, email, frequency, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE
+SET email = EXCLUDED.email, frequency = EXCLUDED.frequency, updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetDigestSchedule :one
+SELECT *
+FROM digest_schedules
+WHERE user_id = $1;
+
+-- name: GetDigestSchedules :many
+SELECT digest_schedules.user_id, email, frequency, last_sent_at, last_sent_post_id, users.name AS user_name
+FROM digest_schedules
+JOIN users
+ON digest_schedules.user_id = users.id
+ORDER BY users.name;
+
+-- name: DeleteDigestSchedule :execrows
+DELETE FROM digest_schedules
+WHERE user_id = $1;
+
+-- name: MarkDigestSent :exec
+UPDATE digest_schedules
+SET last_sent_at = $2, last_sent_post_id = $3
+WHERE user_id = $1;
+
+-- name: GetDigestPosts :many
+SELECT posts.id, title, url, description, published_at, posts.feed_id,
+    COALESCE(feed_follows.alias, feeds.name)::text AS feed_name, posts.created_at
+FROM posts
+JOIN feed_follows
+ON posts.feed_id = feed_follows.feed_id
+JOIN feeds
+ON posts.feed_id = feeds.id
+LEFT JOIN user_posts
+ON posts.id = user_posts.post_id AND user_posts.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = @user_id
+    AND user_posts.read_at IS NULL
+    AND (posts.created_at, posts.id) > (@since::timestamp, @after_id::integer)
+-- oldest first so that a digest cut short by the limit can be continued
+ORDER BY posts.created_at, posts.id
+LIMIT sqlc.arg('limit');
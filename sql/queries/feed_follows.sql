@@ -1,9 +1,9 @@
 -- name: CreateFeedFollow :one
 WITH inserted_feed_follow AS (
-    INSERT INTO feed_follows(user_id, feed_id, created_at, updated_at)
-    VALUES ($1, $2, $3, $4)
+    INSERT INTO feed_follows(user_id, feed_id, created_at, updated_at, alias)
+    VALUES ($1, $2, $3, $4, $5)
     RETURNING *
-) SELECT inserted_feed_follow.*, feeds.name AS feed_name, users.name AS user_name
+) SELECT inserted_feed_follow.*, COALESCE(inserted_feed_follow.alias, feeds.name)::text AS feed_name, users.name AS user_name
 FROM inserted_feed_follow
 JOIN feeds
 ON inserted_feed_follow.feed_id = feeds.id
@@ -11,13 +11,17 @@ JOIN users
 ON inserted_feed_follow.user_id = users.id;
 
 -- name: GetFeedFollowsForUser :many
-SELECT feed_follows.id, feeds.name AS feed_name, users.name AS user_name
+SELECT feed_follows.id, feed_follows.feed_id, COALESCE(feed_follows.alias, feeds.name)::text AS feed_name,
+    users.name AS user_name, feeds.url AS feed_url, feed_follow_tags.tag
 FROM feed_follows
 JOIN feeds
 ON feed_follows.feed_id = feeds.id
 JOIN users
 ON feed_follows.user_id = users.id
-WHERE feed_follows.user_id = $1;
+LEFT JOIN feed_follow_tags
+ON feed_follows.id = feed_follow_tags.feed_follow_id
+WHERE feed_follows.user_id = $1
+ORDER BY feed_follow_tags.tag NULLS LAST, feed_name;
 
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows
@@ -29,10 +33,42 @@ WHERE feed_follows.user_id = $1 AND feed_id IN (
 
 
 -- name: GetNextToFetch :one
-SELECT f.id, name, url, f.created_at, f.updated_at, last_fetched_at
+SELECT f.id, name, url, f.created_at, f.updated_at, last_fetched_at, extract_content
 FROM feeds f
 JOIN feed_follows ff
 ON f.id = ff.feed_id
-WHERE ff.user_id = $1
+WHERE ff.user_id = @user_id AND NOT EXISTS (
+    -- feeds pushed by a hub are only polled shortly before the subscription expires
+    SELECT 1
+    FROM websub_subscriptions ws
+    WHERE ws.feed_id = f.id AND ws.expires_at > NOW() + INTERVAL '1 day'
+) AND (@tag::text = '' OR EXISTS (
+    SELECT 1
+    FROM feed_follow_tags fft
+    WHERE fft.feed_follow_id = ff.id AND fft.tag = @tag::text
+))
 ORDER BY last_fetched_at NULLS FIRST
-LIMIT 1;
\ No newline at end of file
+LIMIT 1;
+
+-- name: TagFeedFollow :execrows
+INSERT INTO feed_follow_tags(feed_follow_id, tag, created_at)
+SELECT feed_follows.id, @tag::text, NOW()
+FROM feed_follows
+JOIN feeds
+ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = @user_id AND feeds.url = @url
+ON CONFLICT DO NOTHING;
+
+-- name: UntagFeedFollow :execrows
+DELETE FROM feed_follow_tags
+USING feed_follows, feeds
+WHERE feed_follow_tags.feed_follow_id = feed_follows.id
+    AND feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id = @user_id AND feeds.url = @url
+    AND feed_follow_tags.tag = @tag::text;
+
+-- name: SetFeedFollowAlias :execrows
+UPDATE feed_follows
+SET alias = $3, updated_at = NOW()
+FROM feeds
+WHERE feed_follows.feed_id = feeds.id AND feed_follows.user_id = $1 AND feeds.url = $2;
@@ -12,7 +12,37 @@ SELECT *
 FROM feeds
 WHERE url = $1;
 
+-- name: GetFeedByID :one
+SELECT *
+FROM feeds
+WHERE id = $1;
+
+-- name: GetFeedsForUser :many
+SELECT feeds.id, COALESCE(feed_follows.alias, feeds.name)::text AS name, feeds.url, feeds.user_id,
+    feeds.created_at, feeds.updated_at, feeds.last_fetched_at, feeds.last_fetch_error, feeds.extract_content
+FROM feeds
+LEFT JOIN feed_follows ON feed_follows.feed_id = feeds.id AND feed_follows.user_id = $1;
+
+-- name: GetFeedByIDForUser :one
+SELECT feeds.id, COALESCE(feed_follows.alias, feeds.name)::text AS name, feeds.url, feeds.user_id,
+    feeds.created_at, feeds.updated_at, feeds.last_fetched_at, feeds.last_fetch_error, feeds.extract_content
+FROM feeds
+LEFT JOIN feed_follows ON feed_follows.feed_id = feeds.id AND feed_follows.user_id = $2
+WHERE feeds.id = $1;
+
 -- name: MarkFeedFetched :exec
 UPDATE feeds
 SET updated_at = NOW(), last_fetched_at = NOW()
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: SetFeedFetchError :exec
+UPDATE feeds
+SET last_fetch_error = $2
+WHERE id = $1;
+
+
+-- name: SetFeedExtractContent :execrows
+UPDATE feeds
+SET extract_content = $3, updated_at = NOW()
+FROM feed_follows
+WHERE feeds.url = $2 AND feed_follows.feed_id = feeds.id AND feed_follows.user_id = $1;
@@ -0,0 +1,32 @@
+-- name: CreateFilterRule :one
+INSERT INTO filter_rules(
+    user_id, feed_id, action, field, pattern,
+    is_regex, mark_read, created_at, updated_at
+)
+VALUES (
+    $1, $2, $3, $4, $5,
+    $6, $7, $8, $9
+)
+RETURNING *;
+
+-- name: GetFilterRulesForUser :many
+SELECT filter_rules.*, feeds.url AS feed_url
+FROM filter_rules
+LEFT JOIN feeds
+ON filter_rules.feed_id = feeds.id
+WHERE filter_rules.user_id = $1
+ORDER BY filter_rules.id;
+
+-- name: GetIngestFilterRulesForFeed :many
+SELECT filter_rules.*
+FROM filter_rules
+JOIN feed_follows
+ON filter_rules.user_id = feed_follows.user_id
+WHERE feed_follows.feed_id = $1
+    AND (filter_rules.feed_id IS NULL OR filter_rules.feed_id = $1)
+    AND filter_rules.mark_read
+ORDER BY filter_rules.user_id, filter_rules.id;
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules
+WHERE id = $1 AND user_id = $2;
@@ -1,20 +1,102 @@
 -- name: CreatePost :one
 INSERT INTO posts(
     title, url, description, published_at,
-    feed_id, created_at, updated_at
+    feed_id, created_at, updated_at, guid
 )
 VALUES (
     $1, $2, $3, $4,
-    $5, $6, $7
+    $5, $6, $7, $8
 )
 RETURNING *;
 
 -- name: GetPostsForUser :many
 SELECT posts.id, title, url, description, published_at,
-    posts.feed_id, posts.created_at, posts.updated_at
+    posts.feed_id, posts.created_at, posts.updated_at, content, user_posts.read_at,
+    COALESCE(feed_follows.alias, feeds.name)::text AS feed_name, posts.guid
 FROM posts
 JOIN feed_follows
 ON posts.feed_id = feed_follows.feed_id
-WHERE user_id = $1
-ORDER BY published_at DESC
-LIMIT $2;
\ No newline at end of file
+JOIN feeds
+ON posts.feed_id = feeds.id
+LEFT JOIN user_posts
+ON posts.id = user_posts.post_id AND user_posts.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = @user_id
+    AND (NOT @unread_only::boolean OR user_posts.read_at IS NULL)
+    AND (NOT @starred_only::boolean OR user_posts.starred_at IS NOT NULL)
+    AND (@tag::text = '' OR EXISTS (
+        SELECT 1
+        FROM feed_follow_tags
+        WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND feed_follow_tags.tag = @tag::text
+    ))
+    -- a feed is given by its URL or by the name the user sees
+    AND (@feed::text = '' OR feeds.url = @feed::text OR COALESCE(feed_follows.alias, feeds.name) = @feed::text)
+    AND (sqlc.narg('since')::timestamp IS NULL OR posts.published_at >= sqlc.narg('since')::timestamp)
+    AND (sqlc.narg('until')::timestamp IS NULL OR posts.published_at < sqlc.narg('until')::timestamp)
+    -- cursor: only posts that come after the given one in the sort order
+    AND (sqlc.arg('after')::integer = 0 OR (
+        CASE WHEN @sort_by::text = 'fetched' THEN posts.created_at ELSE posts.published_at END, posts.id
+    ) < (
+        SELECT CASE WHEN @sort_by::text = 'fetched' THEN after_post.created_at ELSE after_post.published_at END, after_post.id
+        FROM posts after_post
+        WHERE after_post.id = sqlc.arg('after')::integer
+    ))
+ORDER BY CASE WHEN @sort_by::text = 'fetched' THEN posts.created_at ELSE posts.published_at END DESC, posts.id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: GetPost :one
+SELECT *
+FROM posts
+WHERE id = $1;
+
+-- name: GetPostForUser :one
+SELECT posts.*
+FROM posts
+WHERE posts.id = $1 AND (
+    EXISTS (
+        SELECT 1
+        FROM feed_follows
+        WHERE feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = $2
+    ) OR EXISTS (
+        SELECT 1
+        FROM user_posts
+        WHERE user_posts.post_id = posts.id AND user_posts.user_id = $2 AND user_posts.starred_at IS NOT NULL
+    )
+);
+
+-- name: SetPostContent :exec
+UPDATE posts
+SET content = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SearchPostsForUser :many
+SELECT posts.id, posts.title, posts.url, posts.published_at,
+    COALESCE(feed_follows.alias, feeds.name)::text AS feed_name,
+    ts_rank(posts.search_vector, search_query)::real AS rank,
+    ts_headline(
+        'english',
+        regexp_replace(
+            COALESCE(posts.description, '') || ' ' || COALESCE(posts.content, ''),
+            '<[^>]*>', ' ', 'g'
+        ),
+        search_query,
+        'StartSel=<<, StopSel=>>, MaxWords=30, MinWords=10, MaxFragments=2'
+    )::text AS snippet
+FROM posts
+JOIN feed_follows
+ON posts.feed_id = feed_follows.feed_id
+JOIN feeds
+ON posts.feed_id = feeds.id
+CROSS JOIN websearch_to_tsquery('english', @query::text) search_query
+WHERE feed_follows.user_id = @user_id
+    AND posts.search_vector @@ search_query
+    AND (@feed_url::text = '' OR feeds.url = @feed_url::text)
+    AND (@tag::text = '' OR EXISTS (
+        SELECT 1
+        FROM feed_follow_tags
+        WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND feed_follow_tags.tag = @tag::text
+    ))
+    AND (sqlc.narg('since')::timestamp IS NULL OR posts.published_at >= sqlc.narg('since')::timestamp)
+    AND (sqlc.narg('until')::timestamp IS NULL OR posts.published_at < sqlc.narg('until')::timestamp)
+ORDER BY rank DESC, posts.published_at DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,52 @@
+-- name: CreateSmartFeed :one
+INSERT INTO smart_feeds(user_id, name, query, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetSmartFeed :one
+SELECT *
+FROM smart_feeds
+WHERE user_id = $1 AND name = $2;
+
+-- name: GetSmartFeedsForUser :many
+SELECT *
+FROM smart_feeds
+WHERE user_id = $1
+ORDER BY name;
+
+-- name: DeleteSmartFeed :execrows
+DELETE FROM smart_feeds
+WHERE user_id = $1 AND name = $2;
+
+-- name: GetSmartFeedPosts :many
+SELECT posts.id, title, url, description, published_at,
+    posts.feed_id, posts.created_at, posts.updated_at, content, user_posts.read_at,
+    COALESCE(feed_follows.alias, feeds.name)::text AS feed_name, posts.guid
+FROM posts
+JOIN feed_follows
+ON posts.feed_id = feed_follows.feed_id
+JOIN feeds
+ON posts.feed_id = feeds.id
+LEFT JOIN user_posts
+ON posts.id = user_posts.post_id AND user_posts.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = @user_id
+    AND posts.search_vector @@ websearch_to_tsquery('english', @query::text)
+    AND (NOT @unread_only::boolean OR user_posts.read_at IS NULL)
+    AND (@tag::text = '' OR EXISTS (
+        SELECT 1
+        FROM feed_follow_tags
+        WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND feed_follow_tags.tag = @tag::text
+    ))
+    AND (sqlc.narg('since')::timestamp IS NULL OR posts.published_at >= sqlc.narg('since')::timestamp)
+    AND (sqlc.narg('until')::timestamp IS NULL OR posts.published_at < sqlc.narg('until')::timestamp)
+    -- cursor: only posts that come after the given one in the sort order
+    AND (sqlc.arg('after')::integer = 0 OR (
+        CASE WHEN @sort_by::text = 'fetched' THEN posts.created_at ELSE posts.published_at END, posts.id
+    ) < (
+        SELECT CASE WHEN @sort_by::text = 'fetched' THEN after_post.created_at ELSE after_post.published_at END, after_post.id
+        FROM posts after_post
+        WHERE after_post.id = sqlc.arg('after')::integer
+    ))
+ORDER BY CASE WHEN @sort_by::text = 'fetched' THEN posts.created_at ELSE posts.published_at END DESC, posts.id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
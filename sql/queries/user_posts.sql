@@ -0,0 +1,71 @@
+-- name: MarkPostRead :exec
+INSERT INTO user_posts(user_id, post_id, read_at, created_at, updated_at)
+VALUES ($1, $2, NOW(), NOW(), NOW())
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = COALESCE(user_posts.read_at, EXCLUDED.read_at), updated_at = EXCLUDED.updated_at;
+
+-- name: MarkPostsRead :execrows
+INSERT INTO user_posts(user_id, post_id, read_at, created_at, updated_at)
+SELECT feed_follows.user_id, posts.id, NOW(), NOW(), NOW()
+FROM posts
+JOIN feed_follows
+ON posts.feed_id = feed_follows.feed_id
+JOIN feeds
+ON posts.feed_id = feeds.id
+WHERE feed_follows.user_id = @user_id
+    AND (@feed_url::text = '' OR feeds.url = @feed_url::text)
+    AND (sqlc.narg('before')::timestamp IS NULL OR posts.published_at < sqlc.narg('before')::timestamp)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = EXCLUDED.read_at, updated_at = EXCLUDED.updated_at
+WHERE user_posts.read_at IS NULL;
+
+-- name: StarPost :execrows
+INSERT INTO user_posts(user_id, post_id, starred_at, created_at, updated_at)
+SELECT feed_follows.user_id, posts.id, NOW(), NOW(), NOW()
+FROM posts
+JOIN feed_follows
+ON posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1 AND posts.id = $2
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET starred_at = COALESCE(user_posts.starred_at, EXCLUDED.starred_at), updated_at = EXCLUDED.updated_at;
+
+-- name: UnstarPost :execrows
+UPDATE user_posts
+SET starred_at = NULL, updated_at = NOW()
+WHERE user_id = $1 AND post_id = $2 AND starred_at IS NOT NULL;
+
+-- name: GetStarredPosts :many
+SELECT posts.id, posts.title, posts.url, posts.description, posts.published_at,
+    COALESCE(feed_follows.alias, feeds.name)::text AS feed_name, user_posts.starred_at
+FROM user_posts
+JOIN posts
+ON user_posts.post_id = posts.id
+JOIN feeds
+ON posts.feed_id = feeds.id
+LEFT JOIN feed_follows
+ON feed_follows.feed_id = feeds.id AND feed_follows.user_id = user_posts.user_id
+WHERE user_posts.user_id = $1 AND user_posts.starred_at IS NOT NULL
+ORDER BY user_posts.starred_at DESC;
+
+-- name: GetStarredPostsForExport :many
+SELECT posts.id, title, url, description, published_at,
+    posts.feed_id, posts.created_at, posts.updated_at, content, user_posts.read_at,
+    COALESCE(feed_follows.alias, feeds.name)::text AS feed_name, posts.guid
+FROM user_posts
+JOIN posts
+ON user_posts.post_id = posts.id
+JOIN feeds
+ON posts.feed_id = feeds.id
+-- starred posts are kept after their feed is unfollowed
+LEFT JOIN feed_follows
+ON feed_follows.feed_id = feeds.id AND feed_follows.user_id = user_posts.user_id
+WHERE user_posts.user_id = @user_id AND user_posts.starred_at IS NOT NULL
+    AND (@tag::text = '' OR EXISTS (
+        SELECT 1
+        FROM feed_follow_tags
+        WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND feed_follow_tags.tag = @tag::text
+    ))
+    AND (sqlc.narg('since')::timestamp IS NULL OR posts.published_at >= sqlc.narg('since')::timestamp)
+    AND (sqlc.narg('until')::timestamp IS NULL OR posts.published_at < sqlc.narg('until')::timestamp)
+ORDER BY posts.published_at DESC, posts.id DESC
+LIMIT sqlc.arg('limit');
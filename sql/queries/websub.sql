@@ -0,0 +1,26 @@
+-- name: UpsertWebsubSubscription :one
+INSERT INTO websub_subscriptions(feed_id, hub_url, topic_url, secret, callback_token, pending_mode, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, 'subscribe', $6, $7)
+ON CONFLICT (feed_id) DO UPDATE
+SET hub_url = EXCLUDED.hub_url, topic_url = EXCLUDED.topic_url, pending_mode = EXCLUDED.pending_mode,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetWebsubSubscriptionByToken :one
+SELECT *
+FROM websub_subscriptions
+WHERE callback_token = $1;
+
+-- name: GetWebsubSubscriptionForFeed :one
+SELECT *
+FROM websub_subscriptions
+WHERE feed_id = $1;
+
+-- name: VerifyWebsubSubscription :exec
+UPDATE websub_subscriptions
+SET verified_at = NOW(), expires_at = $2, pending_mode = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteWebsubSubscription :exec
+DELETE FROM websub_subscriptions
+WHERE id = $1;
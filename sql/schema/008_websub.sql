@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE websub_subscriptions(
+    id SERIAL PRIMARY KEY,
+    feed_id INTEGER UNIQUE NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    hub_url TEXT NOT NULL,
+    topic_url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    verified_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    callback_token TEXT UNIQUE NOT NULL,
+    pending_mode TEXT
+);
+
+-- +goose Down
+DROP TABLE websub_subscriptions;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE user_posts
+ADD COLUMN starred_at TIMESTAMP;
+
+CREATE INDEX user_posts_starred_idx ON user_posts(user_id, starred_at)
+WHERE starred_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX user_posts_starred_idx;
+
+ALTER TABLE user_posts
+DROP COLUMN starred_at;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feed_follows
+ADD COLUMN alias TEXT;
+
+ALTER TABLE feeds
+DROP CONSTRAINT feeds_name_key;
+
+-- +goose Down
+ALTER TABLE feeds
+ADD CONSTRAINT feeds_name_key UNIQUE (name);
+
+ALTER TABLE feed_follows
+DROP COLUMN alias;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE smart_feeds(
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    query TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+-- +goose Down
+DROP TABLE smart_feeds;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE digest_schedules(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    frequency TEXT NOT NULL CHECK (frequency IN ('daily', 'weekly')),
+    last_sent_at TIMESTAMP,
+    -- the last post of a digest cut short by the limit, 0 if it covered every post up to last_sent_at
+    last_sent_post_id INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE digest_schedules;
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DIVIgor/gator/internal/database"
+	"github.com/DIVIgor/gator/internal/htmltext"
+	"github.com/DIVIgor/gator/internal/term"
+)
+
+// Posts loaded into the post list at once
+const tuiPostLimit int32 = 500
+const tuiHelp string = "Tab/←→ pane  j/k move  Enter open  n/p next/prev  m read  s star  o browser  u unread/all  e article  r refresh  q quit"
+
+type tuiPane int
+
+const (
+	paneSources tuiPane = iota
+	panePosts
+	paneReader
+)
+
+
+// An entry of the feed pane: all posts, a tag folder, a feed or a smart feed
+type tuiSource struct {
+	label string
+	depth int
+	tag string
+	feedURL string
+	smartFeed *database.SmartFeed
+}
+
+// An article extracted in the background
+type tuiArticle struct {
+	postID int32
+	content string
+	err error
+}
+
+type tui struct {
+	s *state
+	user database.User
+	filters postFilters
+	unreadOnly bool
+
+	sources []tuiSource
+	posts []database.GetPostsForUserRow
+	starred map[int32]bool
+
+	focus tuiPane
+	sourceIdx, sourceTop int
+	postIdx, postTop int
+
+	// the post shown in the reading pane and its rendered lines
+	reading *database.GetPostsForUserRow
+	content string
+	lines []string
+	linesWidth int
+	readerTop int
+
+	status string
+	width, height int
+}
+
+
+// Full-screen reader with feed, post list and reading panes
+func handlerTUI(s *state, cmd command, user database.User) (err error) {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	refresh := flags.Duration("refresh", 5*time.Minute, "interval between background feed fetches, 0 to disable")
+	showAll := flags.Bool("all", false, "show read posts too")
+	_, err = parseFlags(flags, cmd.args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	t := &tui{s: s, user: user, unreadOnly: !*showAll}
+	t.filters, err = loadFilters(s, user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't load filter rules: %w", err)
+	}
+	err = t.loadSources()
+	if err != nil {return}
+	err = t.loadPosts()
+	if err != nil {return}
+
+	restore, err := term.MakeRaw()
+	if err != nil {return}
+	defer restore()
+	fmt.Print(term.EnterScreen)
+	defer fmt.Print(term.LeaveScreen)
+
+	// feed fetching logs would mess up the screen
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	keys := make(chan string)
+	go term.ReadKeys(os.Stdin, keys)
+
+	done := make(chan struct{})
+	defer close(done)
+	refreshNow := make(chan struct{}, 1)
+	refreshed := make(chan struct{})
+	go t.refreshFeeds(*refresh, refreshNow, refreshed, done)
+
+	articles := make(chan tuiArticle)
+
+	for {
+		t.draw()
+
+		select {
+		case key, ok := <-keys:
+			if !ok {return err}
+
+			switch key {
+			case "q", term.KeyCtrlC:
+				return err
+			case "r":
+				select {
+				case refreshNow <- struct{}{}:
+				default:
+				}
+				t.status = "Refreshing feeds..."
+			case "e":
+				t.extractArticle(articles)
+			default:
+				t.handleKey(key)
+			}
+		case <-refreshed:
+			t.reload()
+			t.status = "Refreshed at " + time.Now().Format(t.s.options.timeFormat)
+		case article := <-articles:
+			t.showArticle(article)
+		}
+	}
+}
+
+// Fetch followed feeds in the background like agg does, one feed per tick
+func (t *tui) refreshFeeds(interval time.Duration, now <-chan struct{}, refreshed chan<- struct{}, done <-chan struct{}) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick:
+			scrapeFeeds(t.s, t.user, "")
+		case <-now:
+			// a manual refresh goes through every followed feed, the least recently fetched first
+			follows, err := t.s.db.GetFeedFollowsForUser(context.Background(), t.user.ID)
+			if err != nil {break}
+			feedIDs := map[int32]bool{}
+			for _, follow := range follows {
+				feedIDs[follow.FeedID] = true
+			}
+			for range feedIDs {
+				scrapeFeeds(t.s, t.user, "")
+			}
+		}
+
+		select {
+		case refreshed <- struct{}{}:
+		case <-done:
+			return
+		}
+	}
+}
+
+// Build the feed pane from followed feeds grouped by tag and smart feeds
+func (t *tui) loadSources() (err error) {
+	follows, err := t.s.db.GetFeedFollowsForUser(context.Background(), t.user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get followed feeds: %w", err)
+	}
+	smartFeeds, err := t.s.db.GetSmartFeedsForUser(context.Background(), t.user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get smart feeds: %w", err)
+	}
+
+	// rows come ordered by tag with untagged feeds last
+	t.sources = []tuiSource{{label: "All posts"}}
+	currentTag := ""
+	for _, follow := range follows {
+		if !follow.Tag.Valid {
+			t.sources = append(t.sources, tuiSource{label: follow.FeedName, feedURL: follow.FeedUrl})
+			continue
+		}
+		if follow.Tag.String != currentTag {
+			currentTag = follow.Tag.String
+			t.sources = append(t.sources, tuiSource{label: "▸ " + currentTag, tag: currentTag})
+		}
+		t.sources = append(t.sources, tuiSource{label: follow.FeedName, depth: 1, feedURL: follow.FeedUrl})
+	}
+	for idx := range smartFeeds {
+		t.sources = append(t.sources, tuiSource{label: "⌕ " + smartFeeds[idx].Name, smartFeed: &smartFeeds[idx]})
+	}
+
+	t.sourceIdx = min(t.sourceIdx, len(t.sources)-1)
+	return err
+}
+
+// Load posts of the selected source
+func (t *tui) loadPosts() (err error) {
+	source := t.sources[t.sourceIdx]
+	params := database.GetPostsForUserParams{
+		UserID: t.user.ID,
+		UnreadOnly: t.unreadOnly,
+		Tag: source.tag,
+		Feed: source.feedURL,
+		SortBy: "published",
+	}
+	fetch := func(limit, offset int32) ([]database.GetPostsForUserRow, error) {
+		pageParams := params
+		pageParams.Limit, pageParams.Offset = limit, offset
+		return t.s.db.GetPostsForUser(context.Background(), pageParams)
+	}
+	if source.smartFeed != nil {
+		fetch = smartFeedPager(t.s, *source.smartFeed, params)
+	}
+
+	posts, _, err := getFilteredPosts(fetch, tuiPostLimit, 0, t.filters)
+	if err != nil {
+		return fmt.Errorf("couldn't get posts: %w", err)
+	}
+	starred, err := t.s.db.GetStarredPosts(context.Background(), t.user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get starred posts: %w", err)
+	}
+
+	t.starred = make(map[int32]bool, len(starred))
+	for _, post := range starred {
+		t.starred[post.ID] = true
+	}
+
+	// keep the selected post selected
+	selectedID := int32(0)
+	if t.postIdx < len(t.posts) {
+		selectedID = t.posts[t.postIdx].ID
+	}
+	t.posts = posts
+	t.postIdx = min(t.postIdx, max(len(posts)-1, 0))
+	for idx, post := range posts {
+		if post.ID == selectedID {
+			t.postIdx = idx
+		}
+	}
+
+	return err
+}
+
+// Reload both lists after fetching feeds
+func (t *tui) reload() {
+	err := t.loadSources()
+	if err == nil {
+		err = t.loadPosts()
+	}
+	if err != nil {
+		t.status = err.Error()
+	}
+}
+
+func (t *tui) handleKey(key string) {
+	switch key {
+	case term.KeyTab, term.KeyRight, "l":
+		t.focus = min(t.focus+1, paneReader)
+		return
+	case term.KeyLeft, "h", term.KeyBackspace, term.KeyEscape:
+		t.focus = max(t.focus-1, paneSources)
+		return
+	case "u":
+		t.unreadOnly = !t.unreadOnly
+		t.status = "Showing all posts"
+		if t.unreadOnly {
+			t.status = "Showing unread posts"
+		}
+		t.reload()
+		return
+	case "m":
+		t.markRead()
+		return
+	case "s":
+		t.toggleStar()
+		return
+	case "o":
+		t.openInBrowser()
+		return
+	case "n", "p":
+		t.moveIn(panePosts, map[string]int{"n": 1, "p": -1}[key])
+		t.openPost()
+		return
+	}
+
+	page := t.height - 3
+	moves := map[string]int{
+		"j": 1, term.KeyDown: 1,
+		"k": -1, term.KeyUp: -1,
+		" ": page, term.KeyPageDown: page,
+		"b": -page, term.KeyPageUp: -page,
+		"g": -1 << 30, term.KeyHome: -1 << 30,
+		"G": 1 << 30, term.KeyEnd: 1 << 30,
+	}
+	if delta, ok := moves[key]; ok {
+		t.moveIn(t.focus, delta)
+		return
+	}
+
+	if key != term.KeyEnter {return}
+	switch t.focus {
+	case paneSources:
+		t.postIdx, t.postTop = 0, 0
+		t.posts = nil
+		t.reload()
+		t.focus = panePosts
+	case panePosts:
+		t.openPost()
+		t.focus = paneReader
+	}
+}
+
+// Move the selection of a pane or scroll the reading pane
+func (t *tui) moveIn(pane tuiPane, delta int) {
+	clamp := func(value, length int) int {
+		return max(min(value, length-1), 0)
+	}
+
+	switch pane {
+	case paneSources:
+		t.sourceIdx = clamp(t.sourceIdx+delta, len(t.sources))
+	case panePosts:
+		t.postIdx = clamp(t.postIdx+delta, len(t.posts))
+	case paneReader:
+		t.readerTop = clamp(t.readerTop+delta, len(t.lines))
+	}
+}
+
+// Show the selected post in the reading pane and mark it read
+func (t *tui) openPost() {
+	if len(t.posts) == 0 {return}
+
+	post := t.posts[t.postIdx]
+	t.reading = &post
+	t.content = post.Description.String
+	if post.Content.Valid {
+		t.content = post.Content.String
+	}
+	t.lines = nil
+	t.readerTop = 0
+	t.markRead()
+}
+
+func (t *tui) markRead() {
+	if len(t.posts) == 0 {return}
+
+	post := &t.posts[t.postIdx]
+	if post.ReadAt.Valid {return}
+	err := t.s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+		UserID: t.user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		t.status = fmt.Sprintf("Couldn't mark post read: %v", err)
+		return
+	}
+	// the post stays in the list until the next reload
+	post.ReadAt = sql.NullTime{Time: time.Now(), Valid: true}
+}
+
+func (t *tui) toggleStar() {
+	if len(t.posts) == 0 {return}
+
+	post := t.posts[t.postIdx]
+	var err error
+	if t.starred[post.ID] {
+		_, err = t.s.db.UnstarPost(context.Background(), database.UnstarPostParams{UserID: t.user.ID, PostID: post.ID})
+	} else {
+		_, err = t.s.db.StarPost(context.Background(), database.StarPostParams{UserID: t.user.ID, PostID: post.ID})
+	}
+	if err != nil {
+		t.status = fmt.Sprintf("Couldn't star post: %v", err)
+		return
+	}
+	t.starred[post.ID] = !t.starred[post.ID]
+}
+
+func (t *tui) openInBrowser() {
+	if len(t.posts) == 0 {return}
+
+	post := t.posts[t.postIdx]
+	err := openBrowser(post.Url)
+	if err != nil {
+		t.status = fmt.Sprintf("Couldn't open %s: %v", post.Url, err)
+		return
+	}
+	t.markRead()
+}
+
+// Extract the full article of the opened post without blocking the interface
+func (t *tui) extractArticle(articles chan<- tuiArticle) {
+	if t.reading == nil {return}
+
+	t.status = "Extracting article..."
+	postID := t.reading.ID
+	go func() {
+		post, err := t.s.db.GetPost(context.Background(), postID)
+		if err != nil {
+			articles <- tuiArticle{postID: postID, err: err}
+			return
+		}
+		content, err := extractPostContent(t.s, post)
+		articles <- tuiArticle{postID: postID, content: content, err: err}
+	}()
+}
+
+func (t *tui) showArticle(article tuiArticle) {
+	if article.err != nil {
+		t.status = fmt.Sprintf("Couldn't extract article: %v", article.err)
+		return
+	}
+
+	t.status = "Article extracted"
+	if t.reading == nil || t.reading.ID != article.postID {return}
+	t.content = article.content
+	t.lines = nil
+	t.readerTop = 0
+}
+
+// Redraw the whole screen
+func (t *tui) draw() {
+	width, height, err := term.Size()
+	if err != nil || width < 40 || height < 5 {
+		width, height = max(width, defaultTerminalWidth), max(height, 24)
+	}
+	t.width, t.height = width, height
+
+	rows := height - 2
+	sourcesWidth := max(width/5, 16)
+	postsWidth := width * 2 / 5
+	readerWidth := width - sourcesWidth - postsWidth - 2
+
+	t.sourceTop = scrollTo(t.sourceIdx, t.sourceTop, rows)
+	t.postTop = scrollTo(t.postIdx, t.postTop, rows)
+	if t.reading != nil && (t.lines == nil || t.linesWidth != readerWidth) {
+		t.lines = t.readerLines(readerWidth - 1)
+		t.linesWidth = readerWidth
+	}
+
+	var screen strings.Builder
+	screen.WriteString(term.Home)
+	screen.WriteString(t.paneTitle(paneSources, "Feeds", sourcesWidth) + "│")
+	screen.WriteString(t.paneTitle(panePosts, fmt.Sprintf("Posts (%d)", len(t.posts)), postsWidth) + "│")
+	screen.WriteString(t.paneTitle(paneReader, "Post", readerWidth) + "\r\n")
+
+	for row := 0; row < rows; row++ {
+		screen.WriteString(t.sourceCell(t.sourceTop+row, sourcesWidth) + "│")
+		screen.WriteString(t.postCell(t.postTop+row, postsWidth) + "│")
+		line := ""
+		if idx := t.readerTop + row; idx < len(t.lines) {
+			line = t.lines[idx]
+		}
+		screen.WriteString(term.Fit(line, readerWidth) + "\r\n")
+	}
+
+	status := t.status
+	if status == "" {
+		status = tuiHelp
+	}
+	screen.WriteString(term.Reverse + term.Fit(status, width) + term.Reset)
+	t.status = ""
+
+	fmt.Print(screen.String())
+}
+
+// Keep the selected row within the visible rows
+func scrollTo(selected, top, rows int) int {
+	if selected < top {
+		return selected
+	}
+	if selected >= top+rows {
+		return selected - rows + 1
+	}
+	return top
+}
+
+func (t *tui) paneTitle(pane tuiPane, title string, width int) string {
+	if t.focus == pane {
+		return term.Reverse + term.Fit(" "+title, width) + term.Reset
+	}
+	return term.Bold + term.Fit(" "+title, width) + term.Reset
+}
+
+func (t *tui) sourceCell(idx, width int) string {
+	if idx >= len(t.sources) {
+		return strings.Repeat(" ", width)
+	}
+
+	source := t.sources[idx]
+	cell := term.Fit(strings.Repeat("  ", source.depth+1)+source.label, width)
+	if idx == t.sourceIdx {
+		return selectedCell(cell, t.focus == paneSources)
+	}
+	return cell
+}
+
+func (t *tui) postCell(idx, width int) string {
+	if idx >= len(t.posts) {
+		return strings.Repeat(" ", width)
+	}
+
+	post := t.posts[idx]
+	read, star := "●", " "
+	if post.ReadAt.Valid {
+		read = " "
+	}
+	if t.starred[post.ID] {
+		star = "★"
+	}
+	cell := term.Fit(fmt.Sprintf("%s%s %s", read, star, post.Title), width)
+	if idx == t.postIdx {
+		return selectedCell(cell, t.focus == panePosts)
+	}
+	return cell
+}
+
+// The selection is highlighted brighter in the focused pane
+func selectedCell(cell string, focused bool) string {
+	if focused {
+		return term.Reverse + cell + term.Reset
+	}
+	return term.Bold + cell + term.Reset
+}
+
+// The opened post with a header, wrapped to the pane width
+func (t *tui) readerLines(width int) []string {
+	post := t.reading
+	lines := []string{
+		post.Title,
+		post.FeedName + " | " + post.PublishedAt.Format(t.s.options.timeFormat),
+		post.Url,
+		strings.Repeat("─", max(width, 0)),
+	}
+	body := htmltext.Render(t.content, max(width, 20))
+	if body == "" {
+		body = "No content, press e to extract the article"
+	}
+	return append(lines, strings.Split(body, "\n")...)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DIVIgor/gator/internal/database"
+	"github.com/DIVIgor/gator/internal/requests"
+)
+
+// requested lease, longer leases granted by hubs are cut to it
+const webSubLease time.Duration = 7 * 24 * time.Hour
+// a subscription is renewed when it expires sooner than this
+const webSubRenewBefore time.Duration = 24 * time.Hour
+// hubs get this long to verify a subscription before it's requested again
+const webSubPendingTimeout time.Duration = time.Hour
+
+// Signature algorithms allowed in X-Hub-Signature
+var webSubHashes = map[string]func() hash.Hash{
+	"sha1": sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+
+// Get the hub advertised by a feed and the topic URL to subscribe to
+func feedHub(feed *RSSFeed, feedURL string) (hub, topic string) {
+	topic = feedURL
+	for _, link := range feed.Channel.AtomLinks {
+		switch strings.ToLower(link.Rel) {
+		case "hub":
+			if hub == "" {
+				hub = link.Href
+			}
+		case "self":
+			topic = link.Href
+		}
+	}
+
+	return hub, topic
+}
+
+// Ask a hub to push updates of a feed unless there's a fresh or pending subscription
+func subscribeWebSub(s *state, feedID int32, hub, topic string) (err error) {
+	if s.cfg.ListenAddr == "" || s.cfg.PublicURL == "" {return}
+
+	existing, err := s.db.GetWebsubSubscriptionForFeed(context.Background(), feedID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {return}
+	if err == nil && existing.HubUrl == hub && existing.TopicUrl == topic {
+		if existing.ExpiresAt.Valid && time.Until(existing.ExpiresAt.Time) > webSubRenewBefore {return}
+		if !existing.VerifiedAt.Valid && time.Since(existing.UpdatedAt) < webSubPendingTimeout {return}
+	}
+
+	secret, err := newWebSubToken()
+	if err != nil {return}
+	// an existing subscription keeps its callback token
+	callbackToken, err := newWebSubToken()
+	if err != nil {return}
+
+	sub, err := s.db.UpsertWebsubSubscription(context.Background(), database.UpsertWebsubSubscriptionParams{
+		FeedID: feedID,
+		HubUrl: hub,
+		TopicUrl: topic,
+		Secret: secret,
+		CallbackToken: callbackToken,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err != nil {return}
+
+	form := url.Values{
+		"hub.mode": {"subscribe"},
+		"hub.topic": {topic},
+		"hub.callback": {webSubCallback(s, sub.CallbackToken)},
+		"hub.secret": {sub.Secret},
+		"hub.lease_seconds": {strconv.Itoa(int(webSubLease.Seconds()))},
+	}
+
+	resp, err := s.client.MakeRequestWith(context.Background(), "POST", hub, strings.NewReader(form.Encode()), requests.Settings{
+		Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+	})
+	if err != nil {return}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("hub responded with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	log.Printf("Subscription to %s requested from hub %s", topic, hub)
+	return err
+}
+
+// Random hex string for secrets and callback tokens
+func newWebSubToken() (token string, err error) {
+	buf := make([]byte, 32)
+	_, err = rand.Read(buf)
+	if err != nil {return}
+
+	return hex.EncodeToString(buf), err
+}
+
+// Callback URLs carry a random token, subscription IDs would be easy to guess
+func webSubCallback(s *state, token string) string {
+	return strings.TrimSuffix(s.cfg.PublicURL, "/") + "/websub/" + token
+}
+
+// Get the subscription addressed by a callback URL
+func callbackSubscription(s *state, r *http.Request) (sub database.WebsubSubscription, err error) {
+	return s.db.GetWebsubSubscriptionByToken(r.Context(), r.PathValue("token"))
+}
+
+// Check that a verification answers a request we're still waiting for
+func webSubPending(sub database.WebsubSubscription, mode string, now time.Time) bool {
+	return sub.PendingMode.Valid && sub.PendingMode.String == mode && now.Sub(sub.UpdatedAt) < webSubPendingTimeout
+}
+
+// Lease granted by a hub, capped to the requested one
+func webSubLeaseExpiry(leaseSeconds string, now time.Time) (expiresAt time.Time, err error) {
+	lease, err := strconv.Atoi(leaseSeconds)
+	if err != nil || lease <= 0 {
+		return expiresAt, fmt.Errorf("invalid lease: %q", leaseSeconds)
+	}
+
+	duration := min(time.Duration(lease)*time.Second, webSubLease)
+	return now.Add(duration), err
+}
+
+// Confirm the intent of a subscription request by echoing the hub challenge
+func handlerWebSubVerify(s *state, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	mode := query.Get("hub.mode")
+
+	sub, err := callbackSubscription(s, r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if query.Get("hub.topic") != sub.TopicUrl {
+		http.NotFound(w, r)
+		return
+	}
+
+	now := time.Now().UTC()
+	switch mode {
+	case "subscribe", "unsubscribe":
+		// only requests that are still pending are confirmed
+		if !webSubPending(sub, mode, now) {
+			http.NotFound(w, r)
+			return
+		}
+
+		if mode == "unsubscribe" {
+			err = s.db.DeleteWebsubSubscription(r.Context(), sub.ID)
+		} else {
+			expiresAt, leaseErr := webSubLeaseExpiry(query.Get("hub.lease_seconds"), now)
+			if leaseErr != nil {
+				http.Error(w, leaseErr.Error(), http.StatusBadRequest)
+				return
+			}
+			err = s.db.VerifyWebsubSubscription(r.Context(), database.VerifyWebsubSubscriptionParams{
+				ID: sub.ID,
+				ExpiresAt: sql.NullTime{Time: expiresAt, Valid: true},
+			})
+		}
+		if err != nil {
+			log.Printf("Couldn't verify subscription %d: %v", sub.ID, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Hub %s confirmed %s to %s", sub.HubUrl, mode, sub.TopicUrl)
+	case "denied":
+		log.Printf("Hub %s denied subscription to %s: %s", sub.HubUrl, sub.TopicUrl, query.Get("hub.reason"))
+		err = s.db.DeleteWebsubSubscription(r.Context(), sub.ID)
+		if err != nil {
+			log.Printf("Couldn't delete subscription %d: %v", sub.ID, err)
+		}
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Write([]byte(query.Get("hub.challenge")))
+}
+
+// Receive content pushed by a hub and save it like a fetched feed
+func handlerWebSubDeliver(s *state, w http.ResponseWriter, r *http.Request) {
+	sub, err := callbackSubscription(s, r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxFeedSize+1))
+	if err != nil || int64(len(body)) > maxFeedSize {
+		http.Error(w, "invalid body", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// invalid messages are acknowledged but ignored as the spec requires
+	w.WriteHeader(http.StatusAccepted)
+
+	if !validWebSubSignature(sub.Secret, r.Header.Get("X-Hub-Signature"), body) {
+		log.Printf("Ignoring content for %s with an invalid signature", sub.TopicUrl)
+		return
+	}
+
+	feed, err := parseXML(body)
+	recordFeedError(s, sub.FeedID, err)
+	if err != nil {
+		log.Printf("Couldn't parse content pushed for %s: %v", sub.TopicUrl, err)
+		return
+	}
+
+	dbFeed, err := s.db.GetFeedByID(context.Background(), sub.FeedID)
+	if err != nil {
+		log.Printf("Couldn't get feed %d: %v", sub.FeedID, err)
+		return
+	}
+
+	log.Printf("Feed %s pushed. Found %v posts.", dbFeed.Name, len(feed.Channel.Item))
+	ingestFeed(s, dbFeed.ID, dbFeed.ExtractContent, feed)
+}
+
+// Check an X-Hub-Signature header in the "method=hexdigest" form
+func validWebSubSignature(secret, header string, body []byte) bool {
+	method, signature, found := strings.Cut(header, "=")
+	if !found {return false}
+
+	newHash, exists := webSubHashes[strings.ToLower(method)]
+	if !exists {return false}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {return false}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DIVIgor/gator/internal/config"
+	"github.com/DIVIgor/gator/internal/requests"
+)
+
+const testTopic string = "https://example.com/atom.xml"
+
+const testAtomFeed string = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<title>Example</title>
+<link rel="self" href="https://example.com/atom.xml"/>
+<entry>
+<id>tag:example.com,2024:1</id>
+<title>First post</title>
+<link href="https://example.com/1"/>
+<updated>2024-05-01T10:00:00Z</updated>
+<content type="html">&lt;p&gt;Hello&lt;/p&gt;</content>
+</entry>
+<entry>
+<id>tag:example.com,2024:2</id>
+<title>Second post</title>
+<link href="https://example.com/2"/>
+<published>2024-05-02T10:00:00Z</published>
+<summary>Plain &lt; text</summary>
+</entry>
+</feed>`
+
+// Subscription stored by the fake database
+type testSubscription struct {
+	hub string
+	token string
+	secret string
+	pending any
+	verifiedAt any
+	expiresAt any
+	updatedAt time.Time
+}
+
+func (sub testSubscription) row() []driver.Value {
+	return []driver.Value{
+		int64(1), int64(7), sub.hub, testTopic, sub.secret, sub.verifiedAt, sub.expiresAt,
+		sub.updatedAt, sub.updatedAt, sub.token, sub.pending,
+	}
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Subscribe through a stand-in hub, verify the subscription and push content to the callback
+func TestWebSubStandInHub(t *testing.T) {
+	var mu sync.Mutex
+	var sub *testSubscription
+	queries := map[string]fakeQuery{
+		"GetWebsubSubscriptionForFeed": func(args []driver.NamedValue) ([][]driver.Value, error) {
+			return nil, nil
+		},
+		"UpsertWebsubSubscription": func(args []driver.NamedValue) ([][]driver.Value, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			sub = &testSubscription{
+				hub: args[1].Value.(string),
+				secret: args[3].Value.(string),
+				token: args[4].Value.(string),
+				pending: "subscribe",
+				updatedAt: args[6].Value.(time.Time),
+			}
+			return [][]driver.Value{sub.row()}, nil
+		},
+		"GetWebsubSubscriptionByToken": func(args []driver.NamedValue) ([][]driver.Value, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if sub == nil || args[0].Value != sub.token {return nil, nil}
+			return [][]driver.Value{sub.row()}, nil
+		},
+		"VerifyWebsubSubscription": func(args []driver.NamedValue) ([][]driver.Value, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			sub.verifiedAt, sub.expiresAt, sub.pending = time.Now().UTC(), args[1].Value, nil
+			return [][]driver.Value{{}}, nil
+		},
+		"SetFeedFetchError": func(args []driver.NamedValue) ([][]driver.Value, error) {
+			return [][]driver.Value{{}}, nil
+		},
+		"GetFeedByID": func(args []driver.NamedValue) ([][]driver.Value, error) {
+			return [][]driver.Value{{
+				int64(7), "Example", testTopic, "00000000-0000-0000-0000-000000000001",
+				time.Now(), time.Now(), nil, nil, false,
+			}}, nil
+		},
+		"CreatePost": func(args []driver.NamedValue) ([][]driver.Value, error) {
+			return [][]driver.Value{{
+				int64(len(args)), args[0].Value, args[1].Value, args[2].Value, args[3].Value,
+				args[4].Value, args[5].Value, args[6].Value, nil, nil, args[7].Value,
+			}}, nil
+		},
+		"GetIngestFilterRulesForFeed": func(args []driver.NamedValue) ([][]driver.Value, error) {
+			return nil, nil
+		},
+	}
+	db, fake := newFakeQueries(t, queries)
+
+	client, err := requests.NewClient(requests.Options{Timeout: 5 * time.Second, Allowlist: []string{"127.0.0.1"}})
+	if err != nil {t.Fatal(err)}
+	s := &state{cfg: &config.Config{ListenAddr: ":0"}, client: &client, db: db}
+
+	callbackServer := httptest.NewServer(newServerMux(s))
+	defer callbackServer.Close()
+	s.cfg.PublicURL = callbackServer.URL
+
+	hubRequests := make(chan url.Values, 1)
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		hubRequests <- r.PostForm
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hub.Close()
+
+	err = subscribeWebSub(s, 7, hub.URL, testTopic)
+	if err != nil {t.Fatalf("subscribe: %v", err)}
+	form := <-hubRequests
+
+	callback := form.Get("hub.callback")
+	if form.Get("hub.mode") != "subscribe" || form.Get("hub.topic") != testTopic {
+		t.Fatalf("unexpected subscription request: %v", form)
+	}
+	if !strings.HasPrefix(callback, callbackServer.URL+"/websub/") || len(callback) < len(callbackServer.URL)+len("/websub/")+64 {
+		t.Fatalf("callback %q doesn't carry a random token", callback)
+	}
+
+	verify := func(target, mode, lease string) (status int, body string) {
+		query := url.Values{
+			"hub.mode": {mode},
+			"hub.topic": {testTopic},
+			"hub.challenge": {"challenge-123"},
+			"hub.lease_seconds": {lease},
+		}
+		resp, err := http.Get(target + "?" + query.Encode())
+		if err != nil {t.Fatal(err)}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	if status, _ := verify(callbackServer.URL+"/websub/1", "subscribe", "3600"); status != http.StatusNotFound {
+		t.Errorf("verification with a guessed callback: got %d, want 404", status)
+	}
+	if status, _ := verify(callbackServer.URL+"/websub/1", "denied", ""); status != http.StatusNotFound {
+		t.Errorf("denial with a guessed callback: got %d, want 404", status)
+	}
+
+	// the hub grants ten years, the lease is cut to the requested one
+	status, body := verify(callback, "subscribe", "315360000")
+	if status != http.StatusOK || body != "challenge-123" {
+		t.Fatalf("verification: got %d %q, want the challenge echoed", status, body)
+	}
+	expiresAt := fake.callsOf("VerifyWebsubSubscription")[0][1].Value.(time.Time)
+	if expiresAt.After(time.Now().Add(webSubLease + time.Minute)) {
+		t.Errorf("lease not capped: expires at %s", expiresAt)
+	}
+
+	// the subscription isn't pending anymore
+	if status, _ := verify(callback, "subscribe", "315360000"); status != http.StatusNotFound {
+		t.Errorf("repeated verification: got %d, want 404", status)
+	}
+
+	push := func(signature string) {
+		req, err := http.NewRequest("POST", callback, strings.NewReader(testAtomFeed))
+		if err != nil {t.Fatal(err)}
+		req.Header.Set("Content-Type", "application/atom+xml")
+		req.Header.Set("X-Hub-Signature", signature)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {t.Fatal(err)}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("push: got %d, want 202", resp.StatusCode)
+		}
+	}
+
+	push(sign("wrong secret", []byte(testAtomFeed)))
+	if calls := fake.callsOf("CreatePost"); len(calls) != 0 {
+		t.Fatalf("content with an invalid signature was saved: %d posts", len(calls))
+	}
+
+	push(sign(sub.secret, []byte(testAtomFeed)))
+	calls := fake.callsOf("CreatePost")
+	if len(calls) != 2 {
+		t.Fatalf("got %d posts saved, want 2", len(calls))
+	}
+	if calls[0][0].Value != "First post" || calls[0][1].Value != "https://example.com/1" || calls[0][7].Value != "tag:example.com,2024:1" {
+		t.Errorf("unexpected first post: %v", calls[0])
+	}
+	if calls[1][2].Value != "Plain &lt; text" {
+		t.Errorf("unexpected description of the second post: %v", calls[1][2].Value)
+	}
+}